@@ -5,9 +5,13 @@ import (
 	"log"
 
 	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/position"
 	"github.com/Open0xScope/CommuneXService/core/redis"
 	"github.com/Open0xScope/CommuneXService/core/task"
 	"github.com/Open0xScope/CommuneXService/core/web"
+	"github.com/Open0xScope/CommuneXService/core/web/handler"
+	"github.com/Open0xScope/CommuneXService/core/whitelist"
 	"github.com/Open0xScope/CommuneXService/utils/logger"
 )
 
@@ -27,14 +31,36 @@ func main() {
 		log.Fatal("load config failed:", err)
 	}
 
+	handler.InitTokenUniverse()
+	handler.InitRateLimitPolicy()
+
 	err = redis.InitRedis()
 	if err != nil {
 		log.Fatal("init redis failed:", err)
 	}
 
+	err = db.InitTables()
+	if err != nil {
+		log.Fatal("init tables failed:", err)
+	}
+
+	err = position.InitLedger()
+	if err != nil {
+		log.Fatal("init position ledger failed:", err)
+	}
+
+	err = whitelist.InitCache()
+	if err != nil {
+		log.Fatal("init whitelist cache failed:", err)
+	}
+
 	task.TradeStatusTask()
 
 	task.MinerStatusTask()
 
+	task.WhitelistCacheTask()
+
+	task.RegistrationSyncTask()
+
 	web.Run()
 }
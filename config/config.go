@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/Open0xScope/CommuneXService/utils/logger"
 	"github.com/fsnotify/fsnotify"
@@ -25,10 +27,140 @@ type RedisConfig struct {
 	MinIdleConns int64  `mapstructure:"MinIdleConns"`
 }
 
+// windows in days used to score the miners
+type ScoringConfig struct {
+	Windows []int64 `mapstructure:"Windows"`
+}
+
+type TokenConfig struct {
+	Address         string  `mapstructure:"Address"`
+	Chain           string  `mapstructure:"Chain"`
+	Symbol          string  `mapstructure:"Symbol"`
+	Decimals        int     `mapstructure:"Decimals"`
+	MinLeverage     float64 `mapstructure:"MinLeverage"`
+	MaxLeverage     float64 `mapstructure:"MaxLeverage"`
+	LeverageStep    float64 `mapstructure:"LeverageStep"`
+	Enabled         bool    `mapstructure:"Enabled"`
+	DailyTradeLimit int64   `mapstructure:"DailyTradeLimit"`
+}
+
+// the tradable universe, the built-in one is used when Tokens is empty
+type TradeConfig struct {
+	Chains []string      `mapstructure:"Chains"`
+	Tokens []TokenConfig `mapstructure:"Tokens"`
+}
+
+// Window is in seconds
+type RateLimitRule struct {
+	Limit  int64 `mapstructure:"Limit"`
+	Window int64 `mapstructure:"Window"`
+}
+
+// Roles and Endpoints override the Policies, an endpoint rule applies to the per-minute policy of the route
+// (query_minute or trade_minute), Exempt lists the addresses or pubkeys without rate limit
+type RateLimitConfig struct {
+	Policies                map[string]RateLimitRule            `mapstructure:"Policies"`
+	Roles                   map[string]map[string]RateLimitRule `mapstructure:"Roles"`
+	Endpoints               map[string]RateLimitRule            `mapstructure:"Endpoints"`
+	Exempt                  []string                            `mapstructure:"Exempt"`
+	LeverageIncreaseLimit   int64                               `mapstructure:"LeverageIncreaseLimit"`
+	LeverageIncreasePenalty int64                               `mapstructure:"LeverageIncreasePenalty"`
+}
+
+// LegacyStatus always write http status 200 for the old clients
+type ResponseConfig struct {
+	LegacyStatus bool `mapstructure:"LegacyStatus"`
+}
+
+// ClockSkew is the accepted distance in seconds between the timestamp of a signed request and now,
+// LegacySign still accepts the queries signed over userId+pubKey+timestamp during the migration to v2, it is on when not set,
+// ChallengeTTL and SessionTTL are the lifetimes in seconds of a login challenge and of a bearer token
+type AuthConfig struct {
+	ClockSkew    int64 `mapstructure:"ClockSkew"`
+	LegacySign   bool  `mapstructure:"LegacySign"`
+	ChallengeTTL int64 `mapstructure:"ChallengeTTL"`
+	SessionTTL   int64 `mapstructure:"SessionTTL"`
+}
+
+func (c AuthConfig) ClockSkewDuration() time.Duration {
+	return time.Duration(c.ClockSkew) * time.Second
+}
+
+func (c AuthConfig) ChallengeTTLDuration() time.Duration {
+	return time.Duration(c.ChallengeTTL) * time.Second
+}
+
+func (c AuthConfig) SessionTTLDuration() time.Duration {
+	return time.Duration(c.SessionTTL) * time.Second
+}
+
+// AcceptedPrefixes are the ss58 network prefixes accepted in the addresses, they are stored re-encoded
+// with CanonicalPrefix, which must be one of them and is the first one when it is not set
+type AddressConfig struct {
+	AcceptedPrefixes []uint16 `mapstructure:"AcceptedPrefixes"`
+	CanonicalPrefix  uint16   `mapstructure:"CanonicalPrefix"`
+}
+
+// ValidatorStake is the stake above which a whitelisted address is a validator, MinerStatus is the
+// minimum whitelist status of a miner, Routes override the roles allowed on a route, the routes open
+// without authentication can not be overridden
+type AccessConfig struct {
+	ValidatorStake int                 `mapstructure:"ValidatorStake"`
+	MinerStatus    int                 `mapstructure:"MinerStatus"`
+	Admins         []string            `mapstructure:"Admins"`
+	Routes         map[string][]string `mapstructure:"Routes"`
+}
+
+// RefreshInterval is the seconds between two reloads of the whitelist cache
+type WhitelistConfig struct {
+	RefreshInterval int64 `mapstructure:"RefreshInterval"`
+}
+
+// ChainSyncConfig is the subnet registrations read from a substrate node, the items are maps with Identity hashers:
+// KeysItem (netuid, uid) -> account, StakeItem (netuid, account) -> stake, RegistrationBlockItem (netuid, uid) -> block.
+// BlockTime and Interval are in seconds
+type ChainSyncConfig struct {
+	Enabled               bool   `mapstructure:"Enabled"`
+	Endpoint              string `mapstructure:"Endpoint"`
+	Netuid                uint16 `mapstructure:"Netuid"`
+	Pallet                string `mapstructure:"Pallet"`
+	KeysItem              string `mapstructure:"KeysItem"`
+	StakeItem             string `mapstructure:"StakeItem"`
+	RegistrationBlockItem string `mapstructure:"RegistrationBlockItem"`
+	BlockTime             int64  `mapstructure:"BlockTime"`
+	Interval              int64  `mapstructure:"Interval"`
+}
+
+// PriceJobConfig is the queue resolving the price marks of the trades at each of the Horizons after them, the
+// times are in seconds. A job is retried after RetryBackoff doubled on each attempt up to MaxBackoff, it is dead
+// after MaxAttempts. A price older than MaxPriceAge at the mark time is no price, VoidDead excludes the trades
+// of the dead jobs. On start the trades of the last BackfillWindow missing a mark are queued
+type PriceJobConfig struct {
+	Horizons       []int64 `mapstructure:"Horizons"`
+	BackfillWindow int64   `mapstructure:"BackfillWindow"`
+	Interval       int64   `mapstructure:"Interval"`
+	BatchSize      int     `mapstructure:"BatchSize"`
+	MaxAttempts    int     `mapstructure:"MaxAttempts"`
+	RetryBackoff   int64   `mapstructure:"RetryBackoff"`
+	MaxBackoff     int64   `mapstructure:"MaxBackoff"`
+	MaxPriceAge    int64   `mapstructure:"MaxPriceAge"`
+	VoidDead       bool    `mapstructure:"VoidDead"`
+}
+
 // struct decode must has tag
 type Config struct {
 	PostgresqlConfig PostgresqlConfig `mapstructure:"PostgresqlConfig"`
 	RedisConf        RedisConfig      `mapstructure:"RedisConfig"`
+	ScoringConf      ScoringConfig    `mapstructure:"ScoringConfig"`
+	ResponseConf     ResponseConfig   `mapstructure:"ResponseConfig"`
+	TradeConf        TradeConfig      `mapstructure:"TradeConfig"`
+	RateLimitConf    RateLimitConfig  `mapstructure:"RateLimitConfig"`
+	AuthConf         AuthConfig       `mapstructure:"AuthConfig"`
+	AddressConf      AddressConfig    `mapstructure:"AddressConfig"`
+	AccessConf       AccessConfig     `mapstructure:"AccessConfig"`
+	WhitelistConf    WhitelistConfig  `mapstructure:"WhitelistConfig"`
+	ChainSyncConf    ChainSyncConfig  `mapstructure:"ChainSyncConfig"`
+	PriceJobConf     PriceJobConfig   `mapstructure:"PriceJobConfig"`
 }
 
 var (
@@ -61,6 +193,47 @@ func watchConfig(c *viper.Viper) error {
 	return nil
 }
 
+// decodeConfig decode the config read by c into a new Config, decoding into the one in use would keep
+// the list entries and the map keys removed from the file
+func decodeConfig(c *viper.Viper) (Config, error) {
+	conf := Config{}
+	if err := c.Unmarshal(&conf); err != nil {
+		return Config{}, err
+	}
+
+	// the legacy signatures are accepted until the migration to v2 is declared over in the config
+	if !c.IsSet("AuthConfig.LegacySign") {
+		conf.AuthConf.LegacySign = true
+	}
+
+	if err := checkAddressConfig(c, &conf.AddressConf); err != nil {
+		return Config{}, err
+	}
+
+	return conf, nil
+}
+
+// checkAddressConfig default the canonical prefix to the first accepted one, a canonical prefix
+// which is not accepted would make every lookup miss
+func checkAddressConfig(c *viper.Viper, conf *AddressConfig) error {
+	if len(conf.AcceptedPrefixes) == 0 {
+		return nil
+	}
+
+	if !c.IsSet("AddressConfig.CanonicalPrefix") {
+		conf.CanonicalPrefix = conf.AcceptedPrefixes[0]
+		return nil
+	}
+
+	for _, prefix := range conf.AcceptedPrefixes {
+		if prefix == conf.CanonicalPrefix {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("AddressConfig CanonicalPrefix %d is not in AcceptedPrefixes %v", conf.CanonicalPrefix, conf.AcceptedPrefixes)
+}
+
 func LoadConf(configFilePath string) error {
 	config = Config{}
 	configMutex.Lock()
@@ -74,9 +247,11 @@ func LoadConf(configFilePath string) error {
 	if err := configViper.ReadInConfig(); err != nil {
 		return err
 	}
-	if err := configViper.Unmarshal(&config); err != nil {
+	conf, err := decodeConfig(configViper)
+	if err != nil {
 		return err
 	}
+	config = conf
 
 	logger.Logrus.WithFields(logrus.Fields{"Config": config}).Info("Load config success")
 
@@ -94,9 +269,12 @@ func reloadConfig(c *viper.Viper) {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err.Error()}).Error("config ReLoad failed")
 	}
 
-	if err := configViper.Unmarshal(&config); err != nil {
+	conf, err := decodeConfig(c)
+	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err.Error()}).Error("unmarshal config failed")
+		return
 	}
+	config = conf
 
 	logger.Logrus.WithFields(logrus.Fields{"config": config}).Info("Config ReLoad Success")
 }
@@ -112,3 +290,144 @@ func GetRedisConfig() RedisConfig {
 	defer configMutex.RUnlock()
 	return config.RedisConf
 }
+
+func GetScoringConfig() ScoringConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.ScoringConf
+	if len(conf.Windows) == 0 {
+		conf.Windows = []int64{1, 7, 30}
+	}
+	return conf
+}
+
+func GetResponseConfig() ResponseConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.ResponseConf
+}
+
+func GetTradeConfig() TradeConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.TradeConf
+}
+
+func GetRateLimitConfig() RateLimitConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.RateLimitConf
+}
+
+func GetAuthConfig() AuthConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.AuthConf
+	if conf.ClockSkew <= 0 {
+		conf.ClockSkew = 300
+	}
+	if conf.ChallengeTTL <= 0 {
+		conf.ChallengeTTL = 60
+	}
+	if conf.SessionTTL <= 0 {
+		conf.SessionTTL = 900
+	}
+	return conf
+}
+
+func GetAddressConfig() AddressConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.AddressConf
+	if len(conf.AcceptedPrefixes) == 0 {
+		conf.AcceptedPrefixes = []uint16{42}
+		conf.CanonicalPrefix = 42
+	}
+	return conf
+}
+
+func GetAccessConfig() AccessConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.AccessConf
+	if conf.ValidatorStake <= 0 {
+		conf.ValidatorStake = 1000000000000
+	}
+	if conf.MinerStatus <= 0 {
+		conf.MinerStatus = 1
+	}
+	return conf
+}
+
+func GetWhitelistConfig() WhitelistConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.WhitelistConf
+	if conf.RefreshInterval <= 0 {
+		conf.RefreshInterval = 60
+	}
+	return conf
+}
+
+func GetChainSyncConfig() ChainSyncConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.ChainSyncConf
+	if conf.Pallet == "" {
+		conf.Pallet = "SubspaceModule"
+	}
+	if conf.KeysItem == "" {
+		conf.KeysItem = "Keys"
+	}
+	if conf.StakeItem == "" {
+		conf.StakeItem = "Stake"
+	}
+	if conf.RegistrationBlockItem == "" {
+		conf.RegistrationBlockItem = "RegistrationBlock"
+	}
+	if conf.BlockTime <= 0 {
+		conf.BlockTime = 8
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = 300
+	}
+	return conf
+}
+
+func GetPriceJobConfig() PriceJobConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	conf := config.PriceJobConf
+	if len(conf.Horizons) == 0 {
+		conf.Horizons = []int64{3600, 14400, 86400, 604800}
+	}
+	if conf.BackfillWindow <= 0 {
+		conf.BackfillWindow = 2592000
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = 10
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 500
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = 8
+	}
+	if conf.RetryBackoff <= 0 {
+		conf.RetryBackoff = 60
+	}
+	if conf.MaxBackoff <= 0 {
+		conf.MaxBackoff = 3600
+	}
+	if conf.MaxPriceAge <= 0 {
+		conf.MaxPriceAge = 3600
+	}
+	return conf
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfigDropsRemovedEntries(t *testing.T) {
+	logger.Logrus = logrus.New()
+	logger.Logrus.SetOutput(io.Discard)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	}
+
+	write(`
+TradeConfig:
+  Tokens:
+    - Address: "0xaa"
+    - Address: "0xbb"
+AccessConfig:
+  Admins: ["admin1", "admin2"]
+  Routes:
+    /positions: ["admin"]
+    /quota: ["admin"]
+RateLimitConfig:
+  Exempt: ["exempt1", "exempt2"]
+`)
+
+	c := viper.New()
+	c.SetConfigFile(file)
+	configViper = c
+	reloadConfig(c)
+	require.Len(t, GetTradeConfig().Tokens, 2)
+
+	write(`
+TradeConfig:
+  Tokens:
+    - Address: "0xaa"
+AccessConfig:
+  Admins: ["admin1"]
+  Routes:
+    /quota: ["admin"]
+RateLimitConfig:
+  Exempt: ["exempt1"]
+`)
+	reloadConfig(c)
+
+	require.Equal(t, []TokenConfig{{Address: "0xaa"}}, GetTradeConfig().Tokens)
+	require.Equal(t, []string{"admin1"}, GetAccessConfig().Admins)
+	require.Equal(t, map[string][]string{"/quota": {"admin"}}, GetAccessConfig().Routes)
+	require.Equal(t, []string{"exempt1"}, GetRateLimitConfig().Exempt)
+}
+
+func TestCheckAddressConfig(t *testing.T) {
+	decode := func(content string) (Config, error) {
+		c := viper.New()
+		c.SetConfigType("yaml")
+		require.NoError(t, c.ReadConfig(strings.NewReader(content)))
+		return decodeConfig(c)
+	}
+
+	conf, err := decode("AddressConfig:\n  AcceptedPrefixes: [42, 0]\n")
+	require.NoError(t, err)
+	require.Equal(t, uint16(42), conf.AddressConf.CanonicalPrefix)
+
+	conf, err = decode("AddressConfig:\n  AcceptedPrefixes: [42, 0]\n  CanonicalPrefix: 0\n")
+	require.NoError(t, err)
+	require.Equal(t, uint16(0), conf.AddressConf.CanonicalPrefix)
+
+	_, err = decode("AddressConfig:\n  AcceptedPrefixes: [42]\n  CanonicalPrefix: 7\n")
+	require.Error(t, err)
+}
+
+func TestLegacySignDefault(t *testing.T) {
+	decode := func(content string) Config {
+		c := viper.New()
+		c.SetConfigType("yaml")
+		require.NoError(t, c.ReadConfig(strings.NewReader(content)))
+		conf, err := decodeConfig(c)
+		require.NoError(t, err)
+		return conf
+	}
+
+	require.True(t, decode("RedisConfig:\n  DB: 0\n").AuthConf.LegacySign)
+	require.True(t, decode("AuthConfig:\n  ClockSkew: 300\n").AuthConf.LegacySign)
+	require.False(t, decode("AuthConfig:\n  LegacySign: false\n").AuthConf.LegacySign)
+}
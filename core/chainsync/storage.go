@@ -0,0 +1,134 @@
+package chainsync
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+)
+
+const (
+	keysPageSize    = 1000
+	storageAtChunks = 200
+)
+
+type header struct {
+	Number string `json:"number"`
+}
+
+type storageChangeSet struct {
+	Block   string       `json:"block"`
+	Changes [][2]*string `json:"changes"`
+}
+
+// storagePrefix is twox128(pallet) + twox128(item), the keys of the maps with Identity hashers follow it
+func storagePrefix(pallet, item string) ([]byte, error) {
+	p, err := common.Twox128Hash([]byte(pallet))
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := common.Twox128Hash([]byte(item))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(p, i...), nil
+}
+
+func encodeU16(v uint16) []byte {
+	buf := make([]byte, 2)
+	binary.LittleEndian.PutUint16(buf, v)
+	return buf
+}
+
+// decodeUint read a little endian scale integer of at most 8 bytes
+func decodeUint(b []byte) uint64 {
+	buf := make([]byte, 8)
+	copy(buf, b)
+	return binary.LittleEndian.Uint64(buf)
+}
+
+func decodeHexNumber(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+}
+
+// finalizedHead return the hash and the number of the finalized block, all the reads are done at it
+func finalizedHead(ctx context.Context, c *rpcClient) (string, uint64, error) {
+	var hash string
+	err := c.call(ctx, "chain_getFinalizedHead", &hash)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var h header
+	err = c.call(ctx, "chain_getHeader", &h, hash)
+	if err != nil {
+		return "", 0, err
+	}
+
+	number, err := decodeHexNumber(h.Number)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid block number %s: %v", h.Number, err)
+	}
+
+	return hash, number, nil
+}
+
+// keysPaged return every storage key under the prefix
+func keysPaged(ctx context.Context, c *rpcClient, prefix []byte, at string) ([]string, error) {
+	prefixHex := common.BytesToHex(prefix)
+	res := make([]string, 0)
+
+	startKey := prefixHex
+	for {
+		var page []string
+		err := c.call(ctx, "state_getKeysPaged", &page, prefixHex, keysPageSize, startKey, at)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, page...)
+		if len(page) < keysPageSize {
+			return res, nil
+		}
+		startKey = page[len(page)-1]
+	}
+}
+
+// queryStorage return the values of the keys, the missing values are not in the result
+func queryStorage(ctx context.Context, c *rpcClient, keys []string, at string) (map[string][]byte, error) {
+	res := make(map[string][]byte, len(keys))
+
+	for start := 0; start < len(keys); start += storageAtChunks {
+		end := start + storageAtChunks
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		var sets []storageChangeSet
+		err := c.call(ctx, "state_queryStorageAt", &sets, keys[start:end], at)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, set := range sets {
+			for _, change := range set.Changes {
+				if change[0] == nil || change[1] == nil {
+					continue
+				}
+
+				value, err := common.HexToBytes(*change[1])
+				if err != nil {
+					return nil, err
+				}
+				res[*change[0]] = value
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,203 @@
+package chainsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/ss58"
+	"github.com/Open0xScope/CommuneXService/core/whitelist"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+)
+
+const registerTimeLayout = "2006-01-02 15:04:05"
+
+// Registration is a key registered on the subnet at the finalized block
+type Registration struct {
+	UID           int
+	Address       string
+	Stake         uint64
+	RegisterBlock uint64
+	RegisterTime  time.Time
+}
+
+// FetchRegistrations read the keys, stakes and registration blocks of the subnet at the finalized block,
+// the register time is estimated from the block time
+func FetchRegistrations(ctx context.Context, conf config.ChainSyncConfig) ([]Registration, error) {
+	c := newRPCClient(conf.Endpoint)
+	netuid := encodeU16(conf.Netuid)
+
+	at, head, err := finalizedHead(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("get finalized head failed: %v", err)
+	}
+	now := time.Now().UTC()
+
+	keysPrefix, err := storagePrefix(conf.Pallet, conf.KeysItem)
+	if err != nil {
+		return nil, err
+	}
+	keysPrefix = append(keysPrefix, netuid...)
+
+	keys, err := keysPaged(ctx, c, keysPrefix, at)
+	if err != nil {
+		return nil, fmt.Errorf("get %s keys failed: %v", conf.KeysItem, err)
+	}
+
+	accounts, err := queryStorage(ctx, c, keys, at)
+	if err != nil {
+		return nil, fmt.Errorf("get %s values failed: %v", conf.KeysItem, err)
+	}
+
+	stakePrefix, err := storagePrefix(conf.Pallet, conf.StakeItem)
+	if err != nil {
+		return nil, err
+	}
+	blockPrefix, err := storagePrefix(conf.Pallet, conf.RegistrationBlockItem)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Registration, 0, len(keys))
+	stakeKeys := make([]string, 0, len(keys))
+	blockKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		account, ok := accounts[key]
+		if !ok {
+			continue
+		}
+
+		raw, err := common.HexToBytes(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) != len(keysPrefix)+2 || len(account) != 32 {
+			return nil, fmt.Errorf("unexpected %s entry %s", conf.KeysItem, key)
+		}
+		uid := raw[len(keysPrefix):]
+
+		res = append(res, Registration{
+			UID:     int(decodeUint(uid)),
+			Address: ss58.Encode(config.GetAddressConfig().CanonicalPrefix, account),
+		})
+
+		stakeKeys = append(stakeKeys, common.BytesToHex(append(append(append([]byte{}, stakePrefix...), netuid...), account...)))
+		blockKeys = append(blockKeys, common.BytesToHex(append(append(append([]byte{}, blockPrefix...), netuid...), uid...)))
+	}
+
+	stakes, err := queryStorage(ctx, c, stakeKeys, at)
+	if err != nil {
+		return nil, fmt.Errorf("get %s values failed: %v", conf.StakeItem, err)
+	}
+
+	blocks, err := queryStorage(ctx, c, blockKeys, at)
+	if err != nil {
+		return nil, fmt.Errorf("get %s values failed: %v", conf.RegistrationBlockItem, err)
+	}
+
+	blockTime := time.Duration(conf.BlockTime) * time.Second
+	for i := range res {
+		res[i].Stake = decodeUint(stakes[stakeKeys[i]])
+		res[i].RegisterBlock = decodeUint(blocks[blockKeys[i]])
+
+		age := time.Duration(0)
+		if head > res[i].RegisterBlock {
+			age = time.Duration(head-res[i].RegisterBlock) * blockTime
+		}
+		res[i].RegisterTime = now.Add(-age)
+	}
+
+	return res, nil
+}
+
+// StoreRegistrations upsert the whitelist and the register times, the whitelisted keys missing from the subnet are deregistered
+func StoreRegistrations(ctx context.Context, regs []Registration) error {
+	if len(regs) == 0 {
+		return fmt.Errorf("no registration, the subnet is empty or the node is not synced")
+	}
+
+	now := time.Now().Unix()
+	addresses := make([]string, 0, len(regs))
+	members := make([]model.AdsMinerWhitelist, 0, len(regs))
+	performances := make([]model.AdsMinerPerformance, 0, len(regs))
+	for _, v := range regs {
+		addresses = append(addresses, v.Address)
+		members = append(members, model.AdsMinerWhitelist{
+			Address:   v.Address,
+			UID:       v.UID,
+			Stake:     int(v.Stake),
+			Status:    1,
+			TimeStamp: now,
+		})
+		performances = append(performances, model.AdsMinerPerformance{
+			UID:          v.UID,
+			Address:      v.Address,
+			RegisterTime: v.RegisterTime.Format(registerTimeLayout),
+		})
+	}
+
+	err := db.GetDB().RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// the entries locked by an admin change are not overwritten
+		_, err := tx.NewInsert().Model(&members).
+			On("CONFLICT (address) DO UPDATE").
+			Set("uid = EXCLUDED.uid").
+			Set("stake = EXCLUDED.stake").
+			Set("status = EXCLUDED.status").
+			Set("timestamp = EXCLUDED.timestamp").
+			Where("oat.admin_locked = false").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().Model((*model.AdsMinerWhitelist)(nil)).
+			Set("status = 0").
+			Set("timestamp = ?", now).
+			Where("status > 0").
+			Where("admin_locked = false").
+			Where("address NOT IN (?)", bun.In(addresses)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		// the register time only changes when the uid is taken by another key
+		_, err = tx.NewInsert().Model(&performances).
+			On("CONFLICT (uid) DO UPDATE").
+			Set("address = EXCLUDED.address").
+			Set("register_time = EXCLUDED.register_time").
+			Where("oat.address <> EXCLUDED.address").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return whitelist.Refresh()
+}
+
+// Sync read the subnet registrations from the node and store them
+func Sync() error {
+	ctx := context.Background()
+	conf := config.GetChainSyncConfig()
+
+	regs, err := FetchRegistrations(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = StoreRegistrations(ctx, regs)
+	if err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Netuid": conf.Netuid, "Registrations": len(regs)}).Info("Sync chain registrations success")
+	return nil
+}
@@ -0,0 +1,154 @@
+package chainsync
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/ss58"
+	"github.com/stretchr/testify/require"
+)
+
+// mockNode serve the storage of a substrate node over json-rpc
+type mockNode struct {
+	head    uint64
+	storage map[string]string
+}
+
+func (m *mockNode) set(t *testing.T, pallet, item string, key, value []byte) {
+	prefix, err := storagePrefix(pallet, item)
+	require.NoError(t, err)
+	m.storage[common.BytesToHex(append(prefix, key...))] = common.BytesToHex(value)
+}
+
+func (m *mockNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int64             `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "chain_getFinalizedHead":
+		result = "0x01"
+	case "chain_getHeader":
+		result = map[string]string{"number": "0x" + strconv.FormatUint(m.head, 16)}
+	case "state_getKeysPaged":
+		var prefix, startKey string
+		var count int
+		_ = json.Unmarshal(req.Params[0], &prefix)
+		_ = json.Unmarshal(req.Params[1], &count)
+		_ = json.Unmarshal(req.Params[2], &startKey)
+
+		keys := make([]string, 0)
+		for k := range m.storage {
+			if strings.HasPrefix(k, prefix) && k > startKey {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		if len(keys) > count {
+			keys = keys[:count]
+		}
+		result = keys
+	case "state_queryStorageAt":
+		var keys []string
+		_ = json.Unmarshal(req.Params[0], &keys)
+
+		changes := make([][2]*string, 0, len(keys))
+		for _, k := range keys {
+			key := k
+			if v, ok := m.storage[k]; ok {
+				value := v
+				changes = append(changes, [2]*string{&key, &value})
+			} else {
+				changes = append(changes, [2]*string{&key, nil})
+			}
+		}
+		result = []storageChangeSet{{Block: "0x01", Changes: changes}}
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "error": map[string]interface{}{"code": -32601, "message": "method not found"}})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": result})
+}
+
+func u64(v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, v)
+}
+
+func TestFetchRegistrations(t *testing.T) {
+	conf := config.ChainSyncConfig{
+		Netuid:                3,
+		Pallet:                "SubspaceModule",
+		KeysItem:              "Keys",
+		StakeItem:             "Stake",
+		RegistrationBlockItem: "RegistrationBlock",
+		BlockTime:             8,
+	}
+
+	node := &mockNode{head: 1000, storage: make(map[string]string)}
+	netuid := encodeU16(conf.Netuid)
+
+	accounts := map[uint16][]byte{}
+	for uid := uint16(0); uid < 3; uid++ {
+		account := make([]byte, 32)
+		for i := range account {
+			account[i] = byte(uid + 1)
+		}
+		accounts[uid] = account
+
+		node.set(t, conf.Pallet, conf.KeysItem, append(append([]byte{}, netuid...), encodeU16(uid)...), account)
+		node.set(t, conf.Pallet, conf.StakeItem, append(append([]byte{}, netuid...), account...), u64(uint64(uid)*1000000000000))
+		node.set(t, conf.Pallet, conf.RegistrationBlockItem, append(append([]byte{}, netuid...), encodeU16(uid)...), u64(900+uint64(uid)))
+	}
+
+	// a key of another subnet is not read
+	node.set(t, conf.Pallet, conf.KeysItem, append(encodeU16(4), encodeU16(0)...), make([]byte, 32))
+
+	server := httptest.NewServer(node)
+	defer server.Close()
+	conf.Endpoint = server.URL
+
+	regs, err := FetchRegistrations(context.Background(), conf)
+	require.NoError(t, err)
+	require.Len(t, regs, 3)
+
+	sort.Slice(regs, func(i, j int) bool { return regs[i].UID < regs[j].UID })
+	for i, v := range regs {
+		uid := uint16(i)
+		require.Equal(t, int(uid), v.UID)
+		require.Equal(t, ss58.Encode(ss58.DefaultPrefix, accounts[uid]), v.Address)
+		require.Equal(t, uint64(uid)*1000000000000, v.Stake)
+		require.Equal(t, 900+uint64(uid), v.RegisterBlock)
+
+		// (1000 - block) blocks of 8 seconds ago
+		expect := time.Now().Add(-time.Duration(100-int(uid)) * 8 * time.Second)
+		require.WithinDuration(t, expect, v.RegisterTime, 5*time.Second)
+	}
+}
+
+func TestFetchRegistrationsRPCError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":1,"error":{"code":-32000,"message":"node is syncing"}}`))
+	}))
+	defer server.Close()
+
+	_, err := FetchRegistrations(context.Background(), config.ChainSyncConfig{Endpoint: server.URL, Pallet: "SubspaceModule", KeysItem: "Keys"})
+	require.ErrorContains(t, err, "node is syncing")
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+)
+
+// tables owned by the service itself, the others are filled by the data pipeline
+var serviceTables = []interface{}{
+	(*model.AdsMinerPosition)(nil),
+	(*model.AdsWhitelistAudit)(nil),
+	(*model.AdsTradeStatusHistory)(nil),
+	(*model.AdsMinerStatusSnapshot)(nil),
+	(*model.AdsMinerStatusSweep)(nil),
+	(*model.AdsPriceJob)(nil),
+	(*model.TradePriceMark)(nil),
+}
+
+// columns the service adds to the tables of the data pipeline
+var serviceColumns = []struct {
+	model  interface{}
+	column string
+}{
+	{(*model.AdsTokenTrade)(nil), "status_reason VARCHAR NOT NULL DEFAULT ''"},
+	{(*model.AdsMinerWhitelist)(nil), "admin_locked BOOLEAN NOT NULL DEFAULT false"},
+}
+
+var serviceIndexes = []struct {
+	model   interface{}
+	name    string
+	columns []string
+}{
+	{(*model.AdsTradeStatusHistory)(nil), "ads_trade_status_history_trade_idx", []string{"miner_id", "token", "nonce"}},
+	{(*model.AdsPriceJob)(nil), "ads_price_jobs_due_idx", []string{"status", "due_at"}},
+}
+
+// InitTables create the service owned tables, columns and indexes if not exist
+func InitTables() error {
+	ctx := context.Background()
+	for _, m := range serviceTables {
+		_, err := GetDB().NewCreateTable().Model(m).IfNotExists().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("create table %T,%v", m, err)
+		}
+	}
+
+	for _, c := range serviceColumns {
+		_, err := GetDB().NewAddColumn().Model(c.model).ColumnExpr(c.column).IfNotExists().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("add column %T %s,%v", c.model, c.column, err)
+		}
+	}
+
+	for _, idx := range serviceIndexes {
+		_, err := GetDB().NewCreateIndex().Model(idx.model).Index(idx.name).IfNotExists().Column(idx.columns...).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("create index %s,%v", idx.name, err)
+		}
+	}
+
+	return nil
+}
@@ -34,7 +34,13 @@ type AdsTokenTrade struct {
 	TradePrice4H    float64 `bun:"price_4h"`
 	Signature       string  `bun:"signature,notnull"`
 	Status          int     `bun:"status,pk,notnull"`
+	StatusReason    string  `bun:"status_reason,notnull"`
 	Leverage        float64 `bun:"leverage"`
+	// key type of the pubkey and miner id as signed by the miner, MinerID is its canonical form, they are not stored
+	KeyType       string `bun:"-" json:"-"`
+	SignedMinerID string `bun:"-" json:"-"`
+	// prices of the token at each horizon after the trade by horizon seconds, read with the trades only
+	PriceMarks map[string]float64 `bun:"price_marks,scanonly"`
 
 	CreatedAt time.Time `bun:"create_at,notnull"`
 	UpdatedAt time.Time `bun:"update_at,notnull"`
@@ -52,6 +58,10 @@ type ResTokenTrade struct {
 	TradePrice      float64 `bun:"price,notnull"`
 	TradePrice4H    float64 `bun:"price_4h"`
 	Leverage        float64 `bun:"leverage"`
+	Status          int     `bun:"status,notnull"`
+	StatusReason    string  `bun:"status_reason,notnull"`
+
+	PriceMarks map[string]float64 `bun:"price_marks,scanonly"`
 
 	CreatedAt time.Time `bun:"create_at,notnull"`
 	UpdatedAt time.Time `bun:"update_at,notnull"`
@@ -75,6 +85,8 @@ type AdsMinerWhitelist struct {
 	Stake     int    `bun:"stake"`
 	Status    int    `bun:"status"`
 	TimeStamp int64  `bun:"timestamp"`
+	// set by the admin changes, the chain sync leaves the locked entries as they are
+	AdminLocked bool `bun:"admin_locked,notnull"`
 }
 
 type AdsMinerPerformance struct {
@@ -84,3 +96,102 @@ type AdsMinerPerformance struct {
 	Address      string `bun:"address"`
 	RegisterTime string `bun:"register_time"`
 }
+
+type AdsMinerPosition struct {
+	bun.BaseModel `bun:"table:ads_miner_positions,alias:oat"`
+
+	MinerID       string  `bun:"miner_id,pk,notnull"`
+	TokenAddress  string  `bun:"token,pk,notnull"`
+	Status        int     `bun:"status,notnull"`
+	Direction     int     `bun:"direction,notnull"`
+	Leverage      float64 `bun:"leverage,notnull"`
+	EntryPrice    float64 `bun:"entry_price,notnull"`
+	OpenNonce     int64   `bun:"open_nonce,notnull"`
+	OpenTimestamp int64   `bun:"open_timestamp,notnull"`
+	RealizedPnl   float64 `bun:"realized_pnl,notnull"`
+	ClosedCount   int64   `bun:"closed_count,notnull"`
+	LastNonce     int64   `bun:"last_nonce,notnull"`
+	LastTimestamp int64   `bun:"last_timestamp,notnull"`
+
+	CreatedAt time.Time `bun:"create_at,notnull"`
+	UpdatedAt time.Time `bun:"update_at,notnull"`
+}
+
+type AdsWhitelistAudit struct {
+	bun.BaseModel `bun:"table:ads_whitelist_audit,alias:oat"`
+
+	ID       int64  `bun:"id,pk,autoincrement"`
+	Address  string `bun:"address,notnull"`
+	Action   string `bun:"action,notnull"`
+	Operator string `bun:"operator,notnull"`
+	// whitelist entry as json before and after the change, empty when there is none
+	Before string `bun:"before"`
+	After  string `bun:"after"`
+
+	CreatedAt time.Time `bun:"create_at,notnull"`
+}
+
+type AdsTradeStatusHistory struct {
+	bun.BaseModel `bun:"table:ads_trade_status_history,alias:oat"`
+
+	ID           int64  `bun:"id,pk,autoincrement"`
+	MinerID      string `bun:"miner_id,notnull"`
+	Nonce        int64  `bun:"nonce,notnull"`
+	TokenAddress string `bun:"token,notnull"`
+	FromStatus   int    `bun:"from_status,notnull"`
+	ToStatus     int    `bun:"to_status,notnull"`
+	Reason       string `bun:"reason,notnull"`
+
+	CreatedAt time.Time `bun:"create_at,notnull"`
+}
+
+type AdsMinerStatusSnapshot struct {
+	bun.BaseModel `bun:"table:ads_miner_status_snapshot,alias:oat"`
+
+	Address string `bun:"address,pk,notnull"`
+	Status  int    `bun:"status,notnull"`
+
+	UpdatedAt time.Time `bun:"update_at,notnull"`
+}
+
+// AdsMinerStatusSweep is the start of the last sweep, the late trades are looked for from there
+type AdsMinerStatusSweep struct {
+	bun.BaseModel `bun:"table:ads_miner_status_sweep,alias:oat"`
+
+	Name    string    `bun:"name,pk,notnull"`
+	SweptAt time.Time `bun:"swept_at,notnull"`
+}
+
+type AdsPriceJob struct {
+	bun.BaseModel `bun:"table:ads_price_jobs,alias:oat"`
+
+	MinerID      string `bun:"miner_id,pk,notnull"`
+	TokenAddress string `bun:"token,pk,notnull"`
+	Nonce        int64  `bun:"nonce,pk,notnull"`
+	// an auto-close takes the next nonce of its open, the timestamp tells it from a later trade with that nonce
+	Timestamp int64 `bun:"timestamp,pk,notnull"`
+	// seconds after the trade the price is resolved at
+	Horizon   int64  `bun:"horizon,pk,notnull"`
+	DueAt     int64  `bun:"due_at,notnull"`
+	Status    string `bun:"status,notnull"`
+	Attempts  int    `bun:"attempts,notnull"`
+	LastError string `bun:"last_error,notnull"`
+
+	CreatedAt time.Time `bun:"create_at,notnull"`
+	UpdatedAt time.Time `bun:"update_at,notnull"`
+}
+
+type TradePriceMark struct {
+	bun.BaseModel `bun:"table:trade_price_marks,alias:oat"`
+
+	MinerID      string  `bun:"miner_id,pk,notnull"`
+	TokenAddress string  `bun:"token,pk,notnull"`
+	Nonce        int64   `bun:"nonce,pk,notnull"`
+	Timestamp    int64   `bun:"timestamp,pk,notnull"`
+	Horizon      int64   `bun:"horizon,pk,notnull"`
+	Price        float64 `bun:"price,notnull"`
+	// time of the price used for the mark
+	PriceAt time.Time `bun:"price_at,notnull"`
+
+	CreatedAt time.Time `bun:"create_at,notnull"`
+}
@@ -0,0 +1,233 @@
+package position
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/scoring"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+)
+
+const (
+	StatusFlat = 0
+	StatusOpen = 1
+)
+
+// apply move the position to the state after the trade
+func apply(pos *model.AdsMinerPosition, trade *model.AdsTokenTrade) {
+	switch trade.PositionManager {
+	case "open":
+		pos.Status = StatusOpen
+		pos.Direction = trade.Direction
+		pos.Leverage = trade.Leverage
+		pos.EntryPrice = trade.TradePrice
+		pos.OpenNonce = trade.Nonce
+		pos.OpenTimestamp = trade.Timestamp
+	case "close":
+		if pos.Status == StatusOpen {
+			pos.RealizedPnl += scoring.TradeReturn(pos.Direction, pos.Leverage, pos.EntryPrice, trade.TradePrice)
+			pos.ClosedCount++
+		}
+		pos.Status = StatusFlat
+	}
+
+	pos.LastNonce = trade.Nonce
+	pos.LastTimestamp = trade.Timestamp
+	pos.UpdatedAt = time.Now().UTC()
+}
+
+// replay build the position of the miner and token from its trades in time order
+func replay(minerID, token string, trades []model.AdsTokenTrade) model.AdsMinerPosition {
+	pos := model.AdsMinerPosition{
+		MinerID:      minerID,
+		TokenAddress: token,
+		CreatedAt:    time.Now().UTC(),
+	}
+	for i := range trades {
+		apply(&pos, &trades[i])
+	}
+
+	return pos
+}
+
+// Apply update the position of the trade's miner and token, it must run in
+// the same transaction which insert the trade
+func Apply(ctx context.Context, idb bun.IDB, trade *model.AdsTokenTrade) error {
+	var pos model.AdsMinerPosition
+	exists := true
+
+	err := idb.NewSelect().Model(&pos).Where("miner_id = ? and token = ?", trade.MinerID, trade.TokenAddress).For("UPDATE").Scan(ctx)
+	if err == sql.ErrNoRows {
+		exists = false
+		pos = model.AdsMinerPosition{
+			MinerID:      trade.MinerID,
+			TokenAddress: trade.TokenAddress,
+			CreatedAt:    time.Now().UTC(),
+		}
+	} else if err != nil {
+		return err
+	}
+
+	apply(&pos, trade)
+
+	if exists {
+		_, err = idb.NewUpdate().Model(&pos).WherePK().Exec(ctx)
+	} else {
+		_, err = idb.NewInsert().Model(&pos).Exec(ctx)
+	}
+
+	return err
+}
+
+// Lock serialize the writers of the trades and the position of the miner and token until the transaction ends
+func Lock(ctx context.Context, idb bun.IDB, minerID, token string) error {
+	_, err := idb.NewRaw("SELECT pg_advisory_xact_lock(hashtext(?))", minerID+":"+token).Exec(ctx)
+	return err
+}
+
+// Rebuild replay the valid trades of the miner and token into its position, it must run in the
+// same transaction which change the status of the trades
+func Rebuild(ctx context.Context, idb bun.IDB, minerID, token string) error {
+	err := Lock(ctx, idb, minerID, token)
+	if err != nil {
+		return err
+	}
+
+	trades := make([]model.AdsTokenTrade, 0)
+	err = idb.NewSelect().Model(&trades).Where("miner_id = ? and token = ? and status > 0", minerID, token).Order("timestamp ASC").Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(trades) == 0 {
+		_, err = idb.NewDelete().Model((*model.AdsMinerPosition)(nil)).Where("miner_id = ? and token = ?", minerID, token).Exec(ctx)
+		return err
+	}
+
+	pos := replay(minerID, token, trades)
+
+	_, err = idb.NewInsert().Model(&pos).
+		On("CONFLICT (miner_id, token) DO UPDATE").
+		Set("status = EXCLUDED.status").
+		Set("direction = EXCLUDED.direction").
+		Set("leverage = EXCLUDED.leverage").
+		Set("entry_price = EXCLUDED.entry_price").
+		Set("open_nonce = EXCLUDED.open_nonce").
+		Set("open_timestamp = EXCLUDED.open_timestamp").
+		Set("realized_pnl = EXCLUDED.realized_pnl").
+		Set("closed_count = EXCLUDED.closed_count").
+		Set("last_nonce = EXCLUDED.last_nonce").
+		Set("last_timestamp = EXCLUDED.last_timestamp").
+		Set("update_at = EXCLUDED.update_at").
+		Exec(ctx)
+	return err
+}
+
+// MaxPageSize is the most positions returned by one page
+const MaxPageSize = 50000
+
+// GetPositions return a page of the positions of miner, all miners when minerID is empty.
+// The pages start at 1 and are ordered by miner and token
+func GetPositions(minerID, token string, openOnly bool, page, limit int) ([]model.AdsMinerPosition, error) {
+	ctx := context.Background()
+	res := make([]model.AdsMinerPosition, 0)
+
+	query := db.GetDB().NewSelect().Model(&res)
+	if minerID != "" {
+		query = query.Where("miner_id = ?", minerID)
+	}
+	if token != "" {
+		query = query.Where("token = ?", token)
+	}
+	if openOnly {
+		query = query.Where("status = ?", StatusOpen)
+	}
+
+	if limit < 1 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	err := query.Order("miner_id ASC", "token ASC").Limit(limit).Offset((page - 1) * limit).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// InitLedger rebuild the ledger from the trade history when it is empty
+func InitLedger() error {
+	ctx := context.Background()
+
+	num, err := db.GetDB().NewSelect().Model((*model.AdsMinerPosition)(nil)).Count(ctx)
+	if err != nil {
+		return err
+	}
+
+	if num > 0 {
+		return nil
+	}
+
+	rows, err := db.GetDB().NewSelect().Model((*model.AdsTokenTrade)(nil)).Where("status > 0").Order("miner_id ASC", "token ASC", "timestamp ASC").Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var pos *model.AdsMinerPosition
+	positions := make([]model.AdsMinerPosition, 0)
+
+	for rows.Next() {
+		var trade model.AdsTokenTrade
+		err = db.GetDB().ScanRow(ctx, rows, &trade)
+		if err != nil {
+			return err
+		}
+
+		if pos == nil || pos.MinerID != trade.MinerID || pos.TokenAddress != trade.TokenAddress {
+			if pos != nil {
+				positions = append(positions, *pos)
+			}
+			pos = &model.AdsMinerPosition{
+				MinerID:      trade.MinerID,
+				TokenAddress: trade.TokenAddress,
+				CreatedAt:    time.Now().UTC(),
+			}
+		}
+
+		apply(pos, &trade)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if pos != nil {
+		positions = append(positions, *pos)
+	}
+
+	for start := 0; start < len(positions); start += 1000 {
+		end := start + 1000
+		if end > len(positions) {
+			end = len(positions)
+		}
+
+		batch := positions[start:end]
+		_, err = db.GetDB().NewInsert().Model(&batch).On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Positions": len(positions)}).Info("InitLedger rebuild positions success")
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package position
+
+import (
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/stretchr/testify/require"
+)
+
+func trade(manager string, nonce int64, direction int, leverage, price float64) model.AdsTokenTrade {
+	return model.AdsTokenTrade{
+		MinerID:         "miner",
+		TokenAddress:    "token",
+		Nonce:           nonce,
+		PositionManager: manager,
+		Direction:       direction,
+		Leverage:        leverage,
+		TradePrice:      price,
+		Timestamp:       1700000000 + nonce,
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name   string
+		trades []model.AdsTokenTrade
+		want   model.AdsMinerPosition
+	}{
+		{
+			name:   "open",
+			trades: []model.AdsTokenTrade{trade("open", 1, 1, 2, 100)},
+			want:   model.AdsMinerPosition{Status: StatusOpen, Direction: 1, Leverage: 2, EntryPrice: 100, OpenNonce: 1, OpenTimestamp: 1700000001, LastNonce: 1, LastTimestamp: 1700000001},
+		},
+		{
+			name:   "close",
+			trades: []model.AdsTokenTrade{trade("open", 1, 1, 2, 100), trade("close", 2, 1, 2, 110)},
+			want:   model.AdsMinerPosition{Status: StatusFlat, Direction: 1, Leverage: 2, EntryPrice: 100, OpenNonce: 1, OpenTimestamp: 1700000001, RealizedPnl: 0.2, ClosedCount: 1, LastNonce: 2, LastTimestamp: 1700000002},
+		},
+		{
+			name:   "short close",
+			trades: []model.AdsTokenTrade{trade("open", 1, -1, 1, 100), trade("close", 2, -1, 1, 110)},
+			want:   model.AdsMinerPosition{Status: StatusFlat, Direction: -1, Leverage: 1, EntryPrice: 100, OpenNonce: 1, OpenTimestamp: 1700000001, RealizedPnl: -0.1, ClosedCount: 1, LastNonce: 2, LastTimestamp: 1700000002},
+		},
+		{
+			// without the auto-close the new open replaces the position and nothing is realized
+			name:   "open after open",
+			trades: []model.AdsTokenTrade{trade("open", 1, 1, 2, 100), trade("open", 2, -1, 3, 120)},
+			want:   model.AdsMinerPosition{Status: StatusOpen, Direction: -1, Leverage: 3, EntryPrice: 120, OpenNonce: 2, OpenTimestamp: 1700000002, LastNonce: 2, LastTimestamp: 1700000002},
+		},
+		{
+			name:   "close without open",
+			trades: []model.AdsTokenTrade{trade("close", 1, 1, 1, 100)},
+			want:   model.AdsMinerPosition{Status: StatusFlat, LastNonce: 1, LastTimestamp: 1700000001},
+		},
+		{
+			// the auto-close realizes the old leverage, the new open takes its own
+			name:   "leverage change",
+			trades: []model.AdsTokenTrade{trade("open", 1, 1, 2, 100), trade("close", 2, 1, 2, 150), trade("open", 3, 1, 5, 150)},
+			want:   model.AdsMinerPosition{Status: StatusOpen, Direction: 1, Leverage: 5, EntryPrice: 150, OpenNonce: 3, OpenTimestamp: 1700000003, RealizedPnl: 1, ClosedCount: 1, LastNonce: 3, LastTimestamp: 1700000003},
+		},
+	}
+
+	for _, v := range cases {
+		var pos model.AdsMinerPosition
+		for i := range v.trades {
+			apply(&pos, &v.trades[i])
+		}
+
+		require.NotZero(t, pos.UpdatedAt, v.name)
+		pos.UpdatedAt = v.want.UpdatedAt
+		require.InDelta(t, v.want.RealizedPnl, pos.RealizedPnl, 1e-9, v.name)
+		pos.RealizedPnl = v.want.RealizedPnl
+		require.Equal(t, v.want, pos, v.name)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	trades := []model.AdsTokenTrade{
+		trade("open", 1, 1, 2, 100),
+		trade("close", 2, 1, 2, 110),
+		trade("open", 3, -1, 1, 110),
+	}
+
+	pos := replay("miner", "token", trades)
+	require.Equal(t, "miner", pos.MinerID)
+	require.Equal(t, "token", pos.TokenAddress)
+	require.Equal(t, StatusOpen, pos.Status)
+	require.Equal(t, int64(1), pos.ClosedCount)
+	require.InDelta(t, 0.2, pos.RealizedPnl, 1e-9)
+
+	// the close voided, the replay of the valid trades keeps the first open until the next one
+	pos = replay("miner", "token", []model.AdsTokenTrade{trades[0], trades[2]})
+	require.Equal(t, StatusOpen, pos.Status)
+	require.Equal(t, int64(0), pos.ClosedCount)
+	require.Zero(t, pos.RealizedPnl)
+	require.Equal(t, int64(3), pos.OpenNonce)
+}
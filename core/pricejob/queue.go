@@ -0,0 +1,260 @@
+package pricejob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/tradestatus"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusDead    = "dead"
+
+	// the horizon of the 4h price kept on the trades
+	Horizon4H = int64(14400)
+)
+
+// ErrNoPrice means there is no usable price of the token at the time
+var ErrNoPrice = errors.New("no price data")
+
+// PriceFunc return the latest price of the token at or before the timestamp and the time of that price
+type PriceFunc func(token string, timestamp int64) (float64, time.Time, error)
+
+// Horizons return the configured horizons, the 4h one is always resolved since the trades keep its price
+func Horizons(conf config.PriceJobConfig) []int64 {
+	res := []int64{Horizon4H}
+	seen := map[int64]bool{Horizon4H: true}
+	for _, h := range conf.Horizons {
+		if h <= 0 || seen[h] {
+			continue
+		}
+		seen[h] = true
+		res = append(res, h)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func newJob(trade *model.AdsTokenTrade, horizon int64, now time.Time) model.AdsPriceJob {
+	return model.AdsPriceJob{
+		MinerID:      trade.MinerID,
+		TokenAddress: trade.TokenAddress,
+		Nonce:        trade.Nonce,
+		Horizon:      horizon,
+		Timestamp:    trade.Timestamp,
+		DueAt:        trade.Timestamp + horizon,
+		Status:       StatusPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// Schedule queue the price jobs of a new trade, one for each horizon due at the trade time plus the horizon
+func Schedule(ctx context.Context, idb bun.IDB, trade *model.AdsTokenTrade) error {
+	now := time.Now().UTC()
+
+	jobs := make([]model.AdsPriceJob, 0)
+	for _, h := range Horizons(config.GetPriceJobConfig()) {
+		jobs = append(jobs, newJob(trade, h, now))
+	}
+
+	_, err := idb.NewInsert().Model(&jobs).On("CONFLICT DO NOTHING").Exec(ctx)
+	return err
+}
+
+// Backfill queue the jobs of the trades of the backfill window missing a mark and of the trades still missing
+// their 4h price, the trades already queued are left as they are
+func Backfill(ctx context.Context, idb bun.IDB) (int64, error) {
+	conf := config.GetPriceJobConfig()
+	since := time.Now().Unix() - conf.BackfillWindow
+
+	total := int64(0)
+	for _, h := range Horizons(conf) {
+		window := schema.SafeQuery("t.timestamp >= ?", []interface{}{since})
+		if h == Horizon4H {
+			window = schema.SafeQuery("(t.timestamp >= ? OR t.price_4h IS NULL OR t.price_4h = 0)", []interface{}{since})
+		}
+
+		res, err := idb.NewRaw(`INSERT INTO ads_price_jobs (miner_id, token, nonce, horizon, timestamp, due_at, status, attempts, last_error, create_at, update_at)
+SELECT t.miner_id, t.token, t.nonce, ?, t.timestamp, t.timestamp + ?, ?, 0, '', now(), now() FROM ads_token_trades AS t
+WHERE ? AND NOT EXISTS (
+	SELECT 1 FROM trade_price_marks AS m
+	WHERE m.miner_id = t.miner_id AND m.token = t.token AND m.nonce = t.nonce AND m.timestamp = t.timestamp AND m.horizon = ?)
+ON CONFLICT DO NOTHING`, h, h, StatusPending, window, h).Exec(ctx)
+		if err != nil {
+			return total, fmt.Errorf("backfill horizon %d,%v", h, err)
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += rows
+	}
+
+	return total, nil
+}
+
+// backoff is the delay before the next attempt once attempts have failed
+func backoff(conf config.PriceJobConfig, attempts int) time.Duration {
+	delay := conf.RetryBackoff
+	for i := 1; i < attempts && delay < conf.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > conf.MaxBackoff {
+		delay = conf.MaxBackoff
+	}
+
+	return time.Duration(delay) * time.Second
+}
+
+// resolvePrice get the price at the trade time plus the horizon and the time of that price, the due time
+// moves on retries. A price older than MaxPriceAge is no price
+func resolvePrice(conf config.PriceJobConfig, job *model.AdsPriceJob, lookup PriceFunc) (float64, time.Time, error) {
+	stamp := job.Timestamp + job.Horizon
+	price, at, err := lookup(job.TokenAddress, stamp)
+	if err == sql.ErrNoRows {
+		return 0, at, ErrNoPrice
+	}
+	if err != nil {
+		return 0, at, err
+	}
+
+	if price <= 0 {
+		return 0, at, fmt.Errorf("%w, price %v at %s", ErrNoPrice, price, at.Format(time.RFC3339))
+	}
+
+	age := time.Unix(stamp, 0).Sub(at)
+	if age > time.Duration(conf.MaxPriceAge)*time.Second {
+		return 0, at, fmt.Errorf("%w, latest price at %s", ErrNoPrice, at.Format(time.RFC3339))
+	}
+
+	return price, at, nil
+}
+
+// complete record the mark of the trade and close the job, the 4h price is also set on the trade unless
+// it already has one from a close within 4 hours
+func complete(ctx context.Context, idb bun.IDB, job *model.AdsPriceJob, price float64, at time.Time, now time.Time) error {
+	return idb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&model.TradePriceMark{
+			MinerID:      job.MinerID,
+			TokenAddress: job.TokenAddress,
+			Nonce:        job.Nonce,
+			Timestamp:    job.Timestamp,
+			Horizon:      job.Horizon,
+			Price:        price,
+			PriceAt:      at.UTC(),
+			CreatedAt:    now,
+		}).On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if job.Horizon == Horizon4H {
+			_, err = tx.NewUpdate().Model((*model.AdsTokenTrade)(nil)).
+				Set("price_4h = ?", price).
+				Where("miner_id = ? and token = ? and nonce = ? and timestamp = ?", job.MinerID, job.TokenAddress, job.Nonce, job.Timestamp).
+				Where("price_4h IS NULL OR price_4h = 0").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		job.Status = StatusDone
+		job.LastError = ""
+		job.UpdatedAt = now
+		_, err = tx.NewUpdate().Model(job).Column("status", "attempts", "last_error", "update_at").WherePK().Exec(ctx)
+		return err
+	})
+}
+
+// fail count the failed attempt, the job is retried later or dead once it runs out of attempts
+func fail(ctx context.Context, idb bun.IDB, conf config.PriceJobConfig, job *model.AdsPriceJob, cause error, now time.Time) error {
+	job.Attempts++
+	job.LastError = cause.Error()
+	job.UpdatedAt = now
+	if job.Attempts < conf.MaxAttempts {
+		job.DueAt = now.Add(backoff(conf, job.Attempts)).Unix()
+		_, err := idb.NewUpdate().Model(job).Column("due_at", "attempts", "last_error", "update_at").WherePK().Exec(ctx)
+		return err
+	}
+
+	return bury(ctx, idb, conf, job, now)
+}
+
+// bury move the job to the dead letters, the trade is excluded when VoidDead
+func bury(ctx context.Context, idb bun.IDB, conf config.PriceJobConfig, job *model.AdsPriceJob, now time.Time) error {
+	job.Status = StatusDead
+	job.UpdatedAt = now
+	return idb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().Model(job).Column("status", "attempts", "last_error", "update_at").WherePK().Exec(ctx)
+		if err != nil || !conf.VoidDead {
+			return err
+		}
+
+		_, err = tradestatus.VoidTrade(ctx, tx, job.MinerID, job.TokenAddress, job.Nonce, tradestatus.ReasonInvalidPrice)
+		return err
+	})
+}
+
+func process(ctx context.Context, idb bun.IDB, conf config.PriceJobConfig, job *model.AdsPriceJob, lookup PriceFunc) error {
+	now := time.Now().UTC()
+
+	exists, err := idb.NewSelect().Model((*model.AdsTokenTrade)(nil)).
+		Where("miner_id = ? and token = ? and nonce = ? and timestamp = ?", job.MinerID, job.TokenAddress, job.Nonce, job.Timestamp).
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		job.LastError = "trade not found"
+		return bury(ctx, idb, conf, job, now)
+	}
+
+	price, at, err := resolvePrice(conf, job, lookup)
+	if err != nil {
+		return fail(ctx, idb, conf, job, err, now)
+	}
+
+	return complete(ctx, idb, job, price, at, now)
+}
+
+// RunDue process the pending jobs that are due, oldest first, it returns the number of jobs processed
+func RunDue(ctx context.Context, idb bun.IDB, lookup PriceFunc) (int, error) {
+	conf := config.GetPriceJobConfig()
+
+	jobs := make([]model.AdsPriceJob, 0)
+	err := idb.NewSelect().Model(&jobs).
+		Where("status = ? and due_at <= ?", StatusPending, time.Now().Unix()).
+		Order("due_at ASC").Limit(conf.BatchSize).Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		err = process(ctx, idb, conf, job, lookup)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Job": job, "ErrMsg": err}).Error("RunDue process price job failed")
+			continue
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"Job": job}).Debug("RunDue process price job info")
+	}
+
+	return len(jobs), nil
+}
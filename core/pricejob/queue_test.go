@@ -0,0 +1,79 @@
+package pricejob
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func TestBackoff(t *testing.T) {
+	conf := config.PriceJobConfig{RetryBackoff: 60, MaxBackoff: 600}
+
+	require.Equal(t, 60*time.Second, backoff(conf, 1))
+	require.Equal(t, 120*time.Second, backoff(conf, 2))
+	require.Equal(t, 480*time.Second, backoff(conf, 4))
+	require.Equal(t, 600*time.Second, backoff(conf, 5))
+	require.Equal(t, 600*time.Second, backoff(conf, 50))
+}
+
+func TestResolvePrice(t *testing.T) {
+	conf := config.PriceJobConfig{MaxPriceAge: 3600}
+	job := &model.AdsPriceJob{TokenAddress: "token", Timestamp: 1700000000, Horizon: Horizon4H, DueAt: 1700100000}
+	stamp := time.Unix(job.Timestamp+Horizon4H, 0)
+
+	lookup := func(price float64, at time.Time, err error) PriceFunc {
+		return func(token string, timestamp int64) (float64, time.Time, error) {
+			require.Equal(t, "token", token)
+			require.Equal(t, job.Timestamp+Horizon4H, timestamp)
+			return price, at, err
+		}
+	}
+
+	price, at, err := resolvePrice(conf, job, lookup(1.5, stamp.Add(-time.Minute), nil))
+	require.NoError(t, err)
+	require.Equal(t, 1.5, price)
+	require.Equal(t, stamp.Add(-time.Minute), at)
+
+	_, _, err = resolvePrice(conf, job, lookup(0, time.Time{}, sql.ErrNoRows))
+	require.ErrorIs(t, err, ErrNoPrice)
+
+	_, _, err = resolvePrice(conf, job, lookup(1.5, stamp.Add(-2*time.Hour), nil))
+	require.ErrorIs(t, err, ErrNoPrice)
+
+	_, _, err = resolvePrice(conf, job, lookup(0, stamp, nil))
+	require.ErrorIs(t, err, ErrNoPrice)
+
+	failure := errors.New("connection refused")
+	_, _, err = resolvePrice(conf, job, lookup(0, time.Time{}, failure))
+	require.ErrorIs(t, err, failure)
+}
+
+func TestHorizons(t *testing.T) {
+	require.Equal(t, []int64{3600, 14400, 86400, 604800}, Horizons(config.PriceJobConfig{Horizons: []int64{604800, 3600, 86400, 3600, 14400}}))
+	require.Equal(t, []int64{3600, 14400}, Horizons(config.PriceJobConfig{Horizons: []int64{3600, 0, -1}}))
+	require.Equal(t, []int64{14400}, Horizons(config.PriceJobConfig{}))
+}
+
+func TestJobKey(t *testing.T) {
+	// an auto-close takes the next nonce of its open and a later open may reuse it, the jobs of both are kept
+	table := pgdialect.New().Tables().Get(reflect.TypeOf((*model.AdsPriceJob)(nil)))
+	pks := make([]string, 0)
+	for _, f := range table.PKs {
+		pks = append(pks, f.Name)
+	}
+	require.Equal(t, []string{"miner_id", "token", "nonce", "timestamp", "horizon"}, pks)
+
+	marks := pgdialect.New().Tables().Get(reflect.TypeOf((*model.TradePriceMark)(nil)))
+	pks = pks[:0]
+	for _, f := range marks.PKs {
+		pks = append(pks, f.Name)
+	}
+	require.Equal(t, []string{"miner_id", "token", "nonce", "timestamp", "horizon"}, pks)
+}
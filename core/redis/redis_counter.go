@@ -30,6 +30,27 @@ func SetCounter(key string, value int64) error {
 	return nil
 }
 
+// IncrCounter increment the counter unless it has an expiration set, it returns the new value
+func IncrCounter(key string) (int64, error) {
+	ctx := context.Background()
+
+	luaScript := `
+        if redis.call("TTL", KEYS[1]) == -1 or redis.call("TTL", KEYS[1]) == -2 then
+            return redis.call("INCR", KEYS[1])
+        else
+            return -1
+        end
+    `
+	result, err := GetRedisInst().Eval(ctx, luaScript, []string{key}).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if result < 0 {
+		return 0, fmt.Errorf("key already has expiration set, cannot modify")
+	}
+	return result, nil
+}
+
 func GetCounterValue(key string) (int64, error) {
 	ctx := context.Background()
 	value, err := GetRedisInst().Get(ctx, key).Result()
@@ -73,3 +94,47 @@ func DelCounter(key string) error {
 	err := GetRedisInst().Del(ctx, key).Err()
 	return err
 }
+
+// CounterState is the value and the ttl of a counter, TTL is -1 when it has no expiration and -2 when it does not exist
+type CounterState struct {
+	Value int64
+	TTL   time.Duration
+}
+
+// GetCounters read the counters of the keys in one round trip
+func GetCounters(keys []string) ([]CounterState, error) {
+	ctx := context.Background()
+
+	pipe := GetRedisInst().Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.TTL(ctx, key)
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	res := make([]CounterState, len(keys))
+	for i := range keys {
+		res[i].TTL = ttls[i].Val()
+
+		value, err := gets[i].Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res[i].Value, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
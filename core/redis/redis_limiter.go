@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// unix time in milliseconds when the oldest request leaves the window
+	Reset int64
+	// the entry of the request in the window, empty when it was not counted
+	Key    string
+	Member string
+}
+
+// RetryAfter is the time to wait before the next request is allowed
+func (r *RateLimitResult) RetryAfter() time.Duration {
+	if r.Allowed {
+		return 0
+	}
+
+	wait := time.Until(time.UnixMilli(r.Reset))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// the counters of the old fixed window are strings, they are dropped on first use
+const slidingWindowScript = `
+    local key = KEYS[1]
+    local now = tonumber(ARGV[1])
+    local window = tonumber(ARGV[2])
+    local limit = tonumber(ARGV[3])
+    local charge = tonumber(ARGV[5])
+
+    if redis.call("TYPE", key).ok == "string" then
+        redis.call("DEL", key)
+    end
+
+    redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+    local count = redis.call("ZCARD", key)
+    local allowed = 0
+    if count < limit then
+        allowed = 1
+        if charge == 1 then
+            redis.call("ZADD", key, now, ARGV[4])
+            redis.call("PEXPIRE", key, window)
+            count = count + 1
+        end
+    end
+
+    local reset = now + window
+    local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+    if oldest[2] then
+        reset = tonumber(oldest[2]) + window
+    end
+
+    return {allowed, count, reset}
+`
+
+func slidingWindow(key string, limit int64, window time.Duration, charge bool) (*RateLimitResult, error) {
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+
+	chargeArg := 0
+	if charge {
+		chargeArg = 1
+	}
+
+	result, err := GetRedisInst().Eval(ctx, slidingWindowScript, []string{key}, now, window.Milliseconds(), limit, member, chargeArg).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected sliding window result %v", result)
+	}
+
+	allowed, _ := values[0].(int64)
+	count, _ := values[1].(int64)
+	reset, _ := values[2].(int64)
+
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	res := &RateLimitResult{
+		Allowed:   allowed == 1,
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     reset,
+	}
+	if charge && res.Allowed {
+		res.Key = key
+		res.Member = member
+	}
+
+	return res, nil
+}
+
+// SlidingWindowAllow count the request in the window when it is allowed, the rejected ones are not counted
+func SlidingWindowAllow(key string, limit int64, window time.Duration) (*RateLimitResult, error) {
+	return slidingWindow(key, limit, window, true)
+}
+
+// SlidingWindowPeek return the state of the window without counting a request
+func SlidingWindowPeek(key string, limit int64, window time.Duration) (*RateLimitResult, error) {
+	return slidingWindow(key, limit, window, false)
+}
+
+// SlidingWindowRefund remove a counted request from the window
+func SlidingWindowRefund(key, member string) error {
+	return GetRedisInst().ZRem(context.Background(), key, member).Err()
+}
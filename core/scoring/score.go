@@ -0,0 +1,183 @@
+package scoring
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+)
+
+type TradeResult struct {
+	MinerID    string  `json:"miner_id"`
+	Token      string  `json:"token"`
+	Direction  int     `json:"direction"`
+	Leverage   float64 `json:"leverage"`
+	OpenNonce  int64   `json:"open_nonce"`
+	OpenTime   int64   `json:"open_time"`
+	CloseTime  int64   `json:"close_time"`
+	EntryPrice float64 `json:"entry_price"`
+	ExitPrice  float64 `json:"exit_price"`
+	Return     float64 `json:"return"`
+}
+
+type MinerScore struct {
+	MinerID     string  `json:"miner_id"`
+	Trades      int     `json:"trades"`
+	TotalReturn float64 `json:"total_return"`
+	AvgReturn   float64 `json:"avg_return"`
+	WinRate     float64 `json:"win_rate"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	Sharpe      float64 `json:"sharpe"`
+}
+
+// TradeReturn is the leveraged return from entry to exit,
+// direction > 0 is long and the others are short
+func TradeReturn(direction int, leverage, entry, exit float64) float64 {
+	if entry <= 0 || exit <= 0 {
+		return 0
+	}
+
+	ret := (exit - entry) / entry * leverage
+	if direction > 0 {
+		return ret
+	}
+
+	return -ret
+}
+
+func newResult(open *model.AdsTokenTrade, exit float64, closeTime int64) TradeResult {
+	return TradeResult{
+		MinerID:    open.MinerID,
+		Token:      open.TokenAddress,
+		Direction:  open.Direction,
+		Leverage:   open.Leverage,
+		OpenNonce:  open.Nonce,
+		OpenTime:   open.Timestamp,
+		CloseTime:  closeTime,
+		EntryPrice: open.TradePrice,
+		ExitPrice:  exit,
+		Return:     TradeReturn(open.Direction, open.Leverage, open.TradePrice, exit),
+	}
+}
+
+// PairTrades match every open trade with the next close trade of the same miner and token.
+// An open trade without close is marked by its 4h price once it is known,
+// the close time of such a result is 0.
+func PairTrades(trades []model.AdsTokenTrade) []TradeResult {
+	sorted := make([]model.AdsTokenTrade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].MinerID != sorted[j].MinerID {
+			return sorted[i].MinerID < sorted[j].MinerID
+		}
+		if sorted[i].TokenAddress != sorted[j].TokenAddress {
+			return sorted[i].TokenAddress < sorted[j].TokenAddress
+		}
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+
+	res := make([]TradeResult, 0)
+	var pending *model.AdsTokenTrade
+
+	markPending := func() {
+		if pending != nil && pending.TradePrice4H > 0 {
+			res = append(res, newResult(pending, pending.TradePrice4H, 0))
+		}
+		pending = nil
+	}
+
+	for i := range sorted {
+		trade := &sorted[i]
+		if pending != nil && (pending.MinerID != trade.MinerID || pending.TokenAddress != trade.TokenAddress) {
+			markPending()
+		}
+
+		switch trade.PositionManager {
+		case "open":
+			markPending()
+			pending = trade
+		case "close":
+			if pending != nil {
+				res = append(res, newResult(pending, trade.TradePrice, trade.Timestamp))
+				pending = nil
+			}
+		}
+	}
+	markPending()
+
+	return res
+}
+
+// ScoreMiner compute the statistics of the trade results of one miner
+func ScoreMiner(minerID string, results []TradeResult) MinerScore {
+	score := MinerScore{
+		MinerID: minerID,
+		Trades:  len(results),
+	}
+
+	if len(results) == 0 {
+		return score
+	}
+
+	sorted := make([]TradeResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].OpenTime < sorted[j].OpenTime
+	})
+
+	wins := 0
+	equity, peak := 0.0, 0.0
+	for _, v := range sorted {
+		if v.Return > 0 {
+			wins++
+		}
+
+		equity += v.Return
+		if equity > peak {
+			peak = equity
+		}
+		if peak-equity > score.MaxDrawdown {
+			score.MaxDrawdown = peak - equity
+		}
+	}
+
+	n := float64(len(sorted))
+	score.TotalReturn = equity
+	score.AvgReturn = equity / n
+	score.WinRate = float64(wins) / n
+
+	if len(sorted) > 1 {
+		variance := 0.0
+		for _, v := range sorted {
+			variance += (v.Return - score.AvgReturn) * (v.Return - score.AvgReturn)
+		}
+		std := math.Sqrt(variance / (n - 1))
+		if std > 0 {
+			score.Sharpe = score.AvgReturn / std
+		}
+	}
+
+	return score
+}
+
+// Score compute the score of every miner with the results opened since the given time
+func Score(results []TradeResult, since int64) []MinerScore {
+	byMiner := make(map[string][]TradeResult)
+	for _, v := range results {
+		if v.OpenTime < since {
+			continue
+		}
+		byMiner[v.MinerID] = append(byMiner[v.MinerID], v)
+	}
+
+	res := make([]MinerScore, 0, len(byMiner))
+	for minerID, items := range byMiner {
+		res = append(res, ScoreMiner(minerID, items))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].MinerID < res[j].MinerID
+	})
+
+	return res
+}
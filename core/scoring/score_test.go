@@ -0,0 +1,51 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTradeReturn(t *testing.T) {
+	require.InDelta(t, 0.2, TradeReturn(1, 2, 100, 110), 1e-9)
+	require.InDelta(t, -0.2, TradeReturn(-1, 2, 100, 110), 1e-9)
+	require.InDelta(t, 0.1, TradeReturn(0, 1, 100, 90), 1e-9)
+	require.Equal(t, 0.0, TradeReturn(1, 1, 0, 90))
+}
+
+func TestPairTrades(t *testing.T) {
+	trades := []model.AdsTokenTrade{
+		{MinerID: "a", TokenAddress: "t", PositionManager: "close", Timestamp: 20, TradePrice: 110},
+		{MinerID: "a", TokenAddress: "t", PositionManager: "open", Direction: 1, Leverage: 1, Timestamp: 10, TradePrice: 100},
+		{MinerID: "a", TokenAddress: "t", PositionManager: "open", Direction: -1, Leverage: 2, Timestamp: 30, TradePrice: 100, TradePrice4H: 95},
+		{MinerID: "b", TokenAddress: "t", PositionManager: "open", Direction: 1, Leverage: 1, Timestamp: 10, TradePrice: 100},
+	}
+
+	res := PairTrades(trades)
+	require.Len(t, res, 2)
+	require.InDelta(t, 0.1, res[0].Return, 1e-9)
+	require.Equal(t, int64(20), res[0].CloseTime)
+	require.InDelta(t, 0.1, res[1].Return, 1e-9)
+	require.Equal(t, int64(0), res[1].CloseTime)
+}
+
+func TestScoreMiner(t *testing.T) {
+	results := []TradeResult{
+		{OpenTime: 1, Return: 0.1},
+		{OpenTime: 2, Return: -0.3},
+		{OpenTime: 3, Return: 0.1},
+		{OpenTime: 4, Return: 0.2},
+	}
+
+	score := ScoreMiner("a", results)
+	require.Equal(t, 4, score.Trades)
+	require.InDelta(t, 0.1, score.TotalReturn, 1e-9)
+	require.InDelta(t, 0.75, score.WinRate, 1e-9)
+	require.InDelta(t, 0.3, score.MaxDrawdown, 1e-9)
+	require.InDelta(t, 0.025/0.2217355782, score.Sharpe, 1e-6)
+
+	scores := Score(results, 3)
+	require.Len(t, scores, 1)
+	require.Equal(t, 2, scores[0].Trades)
+}
@@ -0,0 +1,76 @@
+package ss58
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// substrate generic prefix
+	DefaultPrefix = 42
+
+	accountLength  = 32
+	checksumLength = 2
+)
+
+var checksumPrefix = []byte("SS58PRE")
+
+func checksum(data []byte) []byte {
+	sum := blake2b.Sum512(append(append([]byte{}, checksumPrefix...), data...))
+	return sum[:checksumLength]
+}
+
+// prefixBytes encode the network prefix, the prefixes from 64 to 16383 take two bytes
+func prefixBytes(prefix uint16) []byte {
+	if prefix < 64 {
+		return []byte{byte(prefix)}
+	}
+
+	first := byte((prefix&0x00fc)>>2) | 0x40
+	second := byte(prefix>>8) | byte(prefix&0x0003)<<6
+	return []byte{first, second}
+}
+
+// Encode return the ss58 address of the account id
+func Encode(prefix uint16, accountID []byte) string {
+	data := append(prefixBytes(prefix), accountID...)
+	return base58.Encode(append(data, checksum(data)...))
+}
+
+// Decode return the network prefix and the account id of the address
+func Decode(addr string) (uint16, []byte, error) {
+	data := base58.Decode(addr)
+	if len(data) == 0 {
+		return 0, nil, errors.New("address is not base58")
+	}
+
+	var prefix uint16
+	prefixLen := 1
+	switch {
+	case data[0] < 64:
+		prefix = uint16(data[0])
+	case data[0] < 128 && len(data) > 1:
+		lower := data[0]<<2 | data[1]>>6
+		upper := data[1] & 0x3f
+		prefix = uint16(lower) | uint16(upper)<<8
+		prefixLen = 2
+	default:
+		return 0, nil, fmt.Errorf("address %s has an invalid prefix", addr)
+	}
+
+	if len(data) != prefixLen+accountLength+checksumLength {
+		return 0, nil, fmt.Errorf("address %s has an invalid length", addr)
+	}
+
+	body := data[:prefixLen+accountLength]
+	sum := data[prefixLen+accountLength:]
+	expect := checksum(body)
+	if sum[0] != expect[0] || sum[1] != expect[1] {
+		return 0, nil, fmt.Errorf("address %s has an invalid checksum", addr)
+	}
+
+	return prefix, body[prefixLen:], nil
+}
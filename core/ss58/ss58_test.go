@@ -0,0 +1,43 @@
+package ss58
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	// well known address of the Alice dev account
+	alice, err := hex.DecodeString("d43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d")
+	require.NoError(t, err)
+
+	cases := []struct {
+		prefix uint16
+		addr   string
+	}{
+		{42, "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"},
+		{0, "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"},
+		{2, "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.addr, Encode(c.prefix, alice))
+
+		prefix, accountID, err := Decode(c.addr)
+		require.NoError(t, err)
+		require.Equal(t, c.prefix, prefix)
+		require.Equal(t, alice, accountID)
+	}
+
+	// two bytes prefixes
+	for _, prefix := range []uint16{64, 255, 1284, 16383} {
+		decoded, accountID, err := Decode(Encode(prefix, alice))
+		require.NoError(t, err)
+		require.Equal(t, prefix, decoded)
+		require.Equal(t, alice, accountID)
+	}
+
+	_, _, err = Decode("5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ")
+	require.Error(t, err)
+}
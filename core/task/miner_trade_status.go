@@ -3,8 +3,9 @@ package task
 import (
 	"context"
 
+	"github.com/Open0xScope/CommuneXService/config"
 	"github.com/Open0xScope/CommuneXService/core/db"
-	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/tradestatus"
 	"github.com/Open0xScope/CommuneXService/utils/logger"
 	cron "github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
@@ -32,28 +33,15 @@ func MinerStatusTask() {
 }
 
 func updateMinerTradeStatus() error {
-
-	ctx := context.Background()
-	var res []model.AdsMinerWhitelist
-
-	err := db.GetDB().NewSelect().Model(&res).Where("status = 0").Scan(ctx)
+	res, err := tradestatus.Sweep(context.Background(), db.GetDB(), config.GetAccessConfig().MinerStatus)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("updateMinerTradeStatus get miner whitelist failed")
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("updateMinerTradeStatus sweep failed")
 		return err
 	}
 
-	// logger.Logrus.WithFields(logrus.Fields{"NoWhiteList": res}).Info("updateMinerTradeStatus  miner nowhitelist info")
-
-	if len(res) != 0 {
-		values := db.GetDB().NewValues(&res)
-
-		rowres, err := db.GetDB().NewUpdate().With("t2", values).Model(&model.AdsTokenTrade{}).TableExpr("t2").Set("status = 0").Where("miner_id = t2.address").Exec(ctx)
-		if err != nil {
-			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("updateMinerTradeStatus set status failed")
-			return err
-		}
-		logger.Logrus.WithFields(logrus.Fields{"UpdateStatusResult": rowres}).Info("updateMinerTradeStatus update trade status result")
-
+	if len(res.Voided)+len(res.Restored)+len(res.Removed) != 0 || res.LateVoidedRows != 0 {
+		logger.Logrus.WithFields(logrus.Fields{"Voided": res.Voided, "Restored": res.Restored, "Removed": res.Removed,
+			"VoidedRows": res.VoidedRows, "RestoredRows": res.RestoredRows, "LateVoidedRows": res.LateVoidedRows, "Duration": res.Duration}).Info("updateMinerTradeStatus update trade status result")
 	}
 
 	return nil
@@ -0,0 +1,46 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/chainsync"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	cron "github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// RegistrationSyncTask sync the whitelist and the register times from the chain when it is enabled
+func RegistrationSyncTask() {
+	defer func() {
+		err := recover()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Stack": PrintStack()}).Fatalf("RegistrationSyncTask panic")
+		}
+	}()
+
+	conf := config.GetChainSyncConfig()
+	if !conf.Enabled {
+		logger.Logrus.Info("RegistrationSyncTask disabled")
+		return
+	}
+
+	sync := func() {
+		err := chainsync.Sync()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("RegistrationSyncTask sync failed")
+		}
+	}
+
+	c := cron.New()
+
+	_, err := c.AddFunc(fmt.Sprintf("@every %ds", conf.Interval), sync)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Fatal("RegistrationSyncTask start failed")
+		return
+	}
+
+	c.Start()
+
+	go sync()
+}
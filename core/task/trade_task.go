@@ -6,8 +6,10 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/Open0xScope/CommuneXService/config"
 	"github.com/Open0xScope/CommuneXService/core/db"
 	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/pricejob"
 	"github.com/Open0xScope/CommuneXService/core/web/handler"
 	"github.com/Open0xScope/CommuneXService/utils/logger"
 	cron "github.com/robfig/cron/v3"
@@ -21,6 +23,7 @@ func PrintStack() string {
 	return string(buf[:n])
 }
 
+// TradeStatusTask resolve the 4h prices of the trades from the price job queue, the trades missing theirs are queued on start
 func TradeStatusTask() {
 	defer func() {
 		err := recover()
@@ -29,9 +32,16 @@ func TradeStatusTask() {
 		}
 	}()
 
+	rows, err := pricejob.Backfill(context.Background(), db.GetDB())
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("TradeStatusTask backfill price jobs failed")
+	} else {
+		logger.Logrus.WithFields(logrus.Fields{"Jobs": rows}).Info("TradeStatusTask backfill price jobs result")
+	}
+
 	c := cron.New()
 
-	_, err := c.AddFunc("@every 10s", func() {
+	_, err = c.AddFunc(fmt.Sprintf("@every %ds", config.GetPriceJobConfig().Interval), func() {
 		updateTrade()
 	})
 	if err != nil {
@@ -43,45 +53,36 @@ func TradeStatusTask() {
 }
 
 func updateTrade() error {
-	ctx := context.Background()
-	var res []model.AdsTokenTrade
-
-	after4h := time.Now().Add(-4 * time.Hour).Unix()
-
-	err := db.GetDB().NewSelect().Model(&res).Where("price_4h is null or price_4h = 0 and timestamp <= ?", after4h).Order("timestamp asc").Scan(ctx)
+	num, err := pricejob.RunDue(context.Background(), db.GetDB(), lookupTokenPrice)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("TradeStatusTask get trades failed")
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("TradeStatusTask get price jobs failed")
 		return err
 	}
 
-	for _, order := range res {
-		err = updateTradePrice4h(order)
-		if err != nil {
-			logger.Logrus.WithFields(logrus.Fields{"Trade": order, "WarnMsg": err}).Warn("TradeStatusTask warn")
-			continue
-		}
-
-		logger.Logrus.WithFields(logrus.Fields{"Trade": order}).Info("TradeStatusTask info")
+	if num != 0 {
+		logger.Logrus.WithFields(logrus.Fields{"Jobs": num}).Info("TradeStatusTask run price jobs result")
 	}
 
 	return nil
 }
 
-func updateTradePrice4h(order model.AdsTokenTrade) error {
-	stamp := order.Timestamp + int64(14400)
-	priceObj, err := getTokenPrice(order.TokenAddress, stamp)
+// the layouts of the price time
+var priceTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339}
+
+func lookupTokenPrice(token string, timestamp int64) (float64, time.Time, error) {
+	priceObj, err := getTokenPrice(token, timestamp)
 	if err != nil {
-		return fmt.Errorf("get token price,%v", err)
+		return 0, time.Time{}, err
 	}
 
-	order.TradePrice4H = priceObj.Price
-
-	_, err = db.GetDB().NewUpdate().Model(&order).Set("price_4h = ?", priceObj.Price).Where("miner_id = ? and token = ? and nonce = ?", order.MinerID, order.TokenAddress, order.Nonce).Exec(context.Background())
-	if err != nil {
-		return fmt.Errorf("update trade 4h price,%v", err)
+	for _, layout := range priceTimeLayouts {
+		at, err := time.Parse(layout, priceObj.Pt)
+		if err == nil {
+			return priceObj.Price, at, nil
+		}
 	}
 
-	return nil
+	return 0, time.Time{}, fmt.Errorf("invalid price time %s", priceObj.Pt)
 }
 
 func getTokenPrice(token string, timestamp int64) (*model.ChainTokenPrice, error) {
@@ -94,7 +95,7 @@ func getTokenPrice(token string, timestamp int64) (*model.ChainTokenPrice, error
 		Table("crawler_ods.ods_crawler_coingecko_trade_token_price").
 		Column("*").
 		ColumnExpr("row_number() OVER (PARTITION BY token_address ORDER BY pt DESC) AS rn").
-		Where("chain IN (?)", bun.In(handler.ChainList)).
+		Where("chain IN (?)", bun.In(handler.ChainList())).
 		Where("token_address = ?", token).
 		Where("pt <= ?", mathtime)
 
@@ -0,0 +1,36 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/whitelist"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	cron "github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+func WhitelistCacheTask() {
+	defer func() {
+		err := recover()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Stack": PrintStack()}).Fatalf("WhitelistCacheTask panic")
+		}
+	}()
+
+	c := cron.New()
+
+	spec := fmt.Sprintf("@every %ds", config.GetWhitelistConfig().RefreshInterval)
+	_, err := c.AddFunc(spec, func() {
+		err := whitelist.Refresh()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("WhitelistCacheTask refresh failed")
+		}
+	})
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Fatal("WhitelistCacheTask start failed")
+		return
+	}
+
+	c.Start()
+}
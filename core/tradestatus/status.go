@@ -0,0 +1,119 @@
+package tradestatus
+
+import (
+	"context"
+
+	"github.com/Open0xScope/CommuneXService/core/position"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	// trades excluded from the scoring
+	StatusVoid  = 0
+	StatusValid = 1
+)
+
+// reasons a trade is excluded, they are kept on the trade and in its status history
+const (
+	ReasonDeregistered = "deregistered"
+	ReasonAdminVoided  = "admin_voided"
+	ReasonInvalidPrice = "invalid_price"
+
+	// reasons of the history rows of the restored trades
+	ReasonReregistered = "reregistered"
+	ReasonAdminEnabled = "admin_enabled"
+)
+
+// the status change and its history rows in one statement, the self join gives the status before the update
+const voidSQL = `WITH changed AS (
+	UPDATE ads_token_trades AS oat SET status = ?, status_reason = ?
+	FROM ads_token_trades AS old
+	WHERE old.miner_id = oat.miner_id AND old.nonce = oat.nonce AND old.token = oat.token
+		AND old.timestamp = oat.timestamp AND old.status = oat.status
+		AND oat.status > ? AND ?
+	RETURNING oat.miner_id, oat.nonce, oat.token, old.status AS from_status
+)
+INSERT INTO ads_trade_status_history (miner_id, nonce, token, from_status, to_status, reason, create_at)
+SELECT miner_id, nonce, token, from_status, ?, ?, now() FROM changed
+RETURNING miner_id, token`
+
+// a restored trade gets back the status it had before its last void, valid when it has no history
+const restoreSQL = `WITH changed AS (
+	UPDATE ads_token_trades AS oat SET status_reason = '', status = COALESCE((
+		SELECT h.from_status FROM ads_trade_status_history AS h
+		WHERE h.miner_id = oat.miner_id AND h.nonce = oat.nonce AND h.token = oat.token AND h.to_status = ?
+		ORDER BY h.id DESC LIMIT 1), ?)
+	WHERE oat.status = ? AND oat.status_reason IN (?) AND ?
+	RETURNING oat.miner_id, oat.nonce, oat.token, oat.status
+)
+INSERT INTO ads_trade_status_history (miner_id, nonce, token, from_status, to_status, reason, create_at)
+SELECT miner_id, nonce, token, ?, status, ?, now() FROM changed
+RETURNING miner_id, token`
+
+func minersIn(addresses []string) schema.QueryWithArgs {
+	return schema.SafeQuery("oat.miner_id IN (?)", []interface{}{bun.In(addresses)})
+}
+
+// changeStatus run the status change and rebuild the positions of the miners and tokens of the trades changed
+func changeStatus(ctx context.Context, idb bun.IDB, query string, args ...interface{}) (int64, error) {
+	var rows []struct {
+		MinerID string `bun:"miner_id"`
+		Token   string `bun:"token"`
+	}
+
+	err := idb.NewRaw(query, args...).Scan(ctx, &rows)
+	if err != nil {
+		return 0, err
+	}
+
+	rebuilt := make(map[[2]string]bool)
+	for _, v := range rows {
+		key := [2]string{v.MinerID, v.Token}
+		if rebuilt[key] {
+			continue
+		}
+		rebuilt[key] = true
+
+		err = position.Rebuild(ctx, idb, v.MinerID, v.Token)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(rows)), nil
+}
+
+func void(ctx context.Context, idb bun.IDB, reason string, where schema.QueryWithArgs) (int64, error) {
+	return changeStatus(ctx, idb, voidSQL, StatusVoid, reason, StatusVoid, where, StatusVoid, reason)
+}
+
+func restore(ctx context.Context, idb bun.IDB, reason string, voidReasons []string, where schema.QueryWithArgs) (int64, error) {
+	return changeStatus(ctx, idb, restoreSQL, StatusVoid, StatusValid, StatusVoid, bun.In(voidReasons), where, StatusVoid, reason)
+}
+
+// VoidMiners exclude every trade of the addresses for the reason, it returns the number of trades changed
+func VoidMiners(ctx context.Context, idb bun.IDB, addresses []string, reason string) (int64, error) {
+	if len(addresses) == 0 {
+		return 0, nil
+	}
+
+	return void(ctx, idb, reason, minersIn(addresses))
+}
+
+// RestoreMiners restore the trades of the addresses excluded for one of the void reasons,
+// reason is recorded in the history, it returns the number of trades changed
+func RestoreMiners(ctx context.Context, idb bun.IDB, addresses []string, reason string, voidReasons ...string) (int64, error) {
+	if len(addresses) == 0 || len(voidReasons) == 0 {
+		return 0, nil
+	}
+
+	return restore(ctx, idb, reason, voidReasons, minersIn(addresses))
+}
+
+// VoidTrade exclude one trade for the reason, it returns the number of trades changed
+func VoidTrade(ctx context.Context, idb bun.IDB, minerID, token string, nonce int64, reason string) (int64, error) {
+	where := schema.SafeQuery("oat.miner_id = ? AND oat.token = ? AND oat.nonce = ?", []interface{}{minerID, token, nonce})
+
+	return void(ctx, idb, reason, where)
+}
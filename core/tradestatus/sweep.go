@@ -0,0 +1,208 @@
+package tradestatus
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	sweepName = "miner_status"
+
+	// a trade checked against a stale whitelist may commit a while after its create time,
+	// the late trades are looked for from this long before the last sweep
+	sweepGrace = time.Minute
+)
+
+var (
+	sweeps        = expvar.NewInt("miner_status_sweeps")
+	sweepChanged  = expvar.NewInt("miner_status_sweep_changed_addresses")
+	sweepRows     = expvar.NewInt("miner_status_sweep_rows")
+	sweepRowsAll  = expvar.NewInt("miner_status_sweep_rows_total")
+	sweepDuration = expvar.NewInt("miner_status_sweep_duration_ms")
+)
+
+// SweepResult is the outcome of one sweep
+type SweepResult struct {
+	Voided   []string
+	Restored []string
+	Removed  []string
+
+	VoidedRows   int64
+	RestoredRows int64
+	// trades of the miners already inactive created since the last sweep
+	LateVoidedRows int64
+	Duration       time.Duration
+}
+
+// statusChanges compare the whitelist with the snapshot of the last sweep. The addresses not in the snapshot
+// are reconciled both ways since their trades may predate the snapshot, the ones gone from the whitelist are
+// only dropped from the snapshot, their trades are left as they are
+func statusChanges(whitelist, snapshot map[string]int, minerStatus int) (voided, restored, removed []string) {
+	for address, status := range whitelist {
+		last, seen := snapshot[address]
+		active := status >= minerStatus
+		if seen && active == (last >= minerStatus) {
+			continue
+		}
+
+		if active {
+			restored = append(restored, address)
+		} else {
+			voided = append(voided, address)
+		}
+	}
+
+	for address := range snapshot {
+		if _, ok := whitelist[address]; !ok {
+			removed = append(removed, address)
+		}
+	}
+
+	return voided, restored, removed
+}
+
+// lateVoids return the inactive addresses not voided by this sweep, their trades created since the last sweep
+// were accepted on a stale whitelist and are voided too
+func lateVoids(whitelist map[string]int, voided []string, minerStatus int) []string {
+	skip := make(map[string]bool, len(voided))
+	for _, address := range voided {
+		skip[address] = true
+	}
+
+	res := make([]string, 0)
+	for address, status := range whitelist {
+		if status < minerStatus && !skip[address] {
+			res = append(res, address)
+		}
+	}
+
+	return res
+}
+
+// lateSince return the create time the late trades are looked for from, every trade when there was no sweep
+func lateSince(last time.Time) time.Time {
+	if last.IsZero() {
+		return last
+	}
+
+	return last.Add(-sweepGrace)
+}
+
+func loadStatus(ctx context.Context, idb bun.IDB, m interface{}, dest *map[string]int) error {
+	var rows []struct {
+		Address string `bun:"address"`
+		Status  int    `bun:"status"`
+	}
+
+	err := idb.NewSelect().Model(m).Column("address", "status").Scan(ctx, &rows)
+	if err != nil {
+		return err
+	}
+
+	*dest = make(map[string]int, len(rows))
+	for _, v := range rows {
+		(*dest)[v.Address] = v.Status
+	}
+
+	return nil
+}
+
+// Sweep void the trades of the miners who lost their status since the last sweep and restore the ones of the miners
+// who got it back, only the addresses changed are touched, plus the trades of the inactive miners created since the
+// last sweep. The snapshot and the sweep time move forward with the trades in one transaction
+func Sweep(ctx context.Context, idb bun.IDB, minerStatus int) (*SweepResult, error) {
+	start := time.Now()
+	res := &SweepResult{}
+
+	err := idb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// one sweep at a time, the snapshot is read and written by this transaction only
+		_, err := tx.NewRaw("SELECT pg_advisory_xact_lock(hashtext(?))", "miner_status_sweep").Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		var whitelist, snapshot map[string]int
+		err = loadStatus(ctx, tx, (*model.AdsMinerWhitelist)(nil), &whitelist)
+		if err != nil {
+			return err
+		}
+		err = loadStatus(ctx, tx, (*model.AdsMinerStatusSnapshot)(nil), &snapshot)
+		if err != nil {
+			return err
+		}
+
+		var last model.AdsMinerStatusSweep
+		err = tx.NewSelect().Model(&last).Where("name = ?", sweepName).Scan(ctx)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		res.Voided, res.Restored, res.Removed = statusChanges(whitelist, snapshot, minerStatus)
+
+		res.VoidedRows, err = VoidMiners(ctx, tx, res.Voided, ReasonDeregistered)
+		if err != nil {
+			return err
+		}
+		res.RestoredRows, err = RestoreMiners(ctx, tx, res.Restored, ReasonReregistered, ReasonDeregistered)
+		if err != nil {
+			return err
+		}
+
+		late := lateVoids(whitelist, res.Voided, minerStatus)
+		if len(late) != 0 {
+			where := schema.SafeQuery("oat.miner_id IN (?) AND oat.create_at >= ?", []interface{}{bun.In(late), lateSince(last.SweptAt)})
+			res.LateVoidedRows, err = void(ctx, tx, ReasonDeregistered, where)
+			if err != nil {
+				return err
+			}
+		}
+
+		changed := make([]model.AdsMinerStatusSnapshot, 0, len(res.Voided)+len(res.Restored))
+		for _, address := range append(append([]string{}, res.Voided...), res.Restored...) {
+			changed = append(changed, model.AdsMinerStatusSnapshot{Address: address, Status: whitelist[address], UpdatedAt: start.UTC()})
+		}
+		if len(changed) != 0 {
+			_, err = tx.NewInsert().Model(&changed).
+				On("CONFLICT (address) DO UPDATE").
+				Set("status = EXCLUDED.status").
+				Set("update_at = EXCLUDED.update_at").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(res.Removed) != 0 {
+			_, err = tx.NewDelete().Model((*model.AdsMinerStatusSnapshot)(nil)).Where("address IN (?)", bun.In(res.Removed)).Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.NewInsert().Model(&model.AdsMinerStatusSweep{Name: sweepName, SweptAt: start.UTC()}).
+			On("CONFLICT (name) DO UPDATE").
+			Set("swept_at = EXCLUDED.swept_at").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res.Duration = time.Since(start)
+
+	rows := res.VoidedRows + res.RestoredRows + res.LateVoidedRows
+	sweeps.Add(1)
+	sweepChanged.Set(int64(len(res.Voided) + len(res.Restored)))
+	sweepRows.Set(rows)
+	sweepRowsAll.Add(rows)
+	sweepDuration.Set(res.Duration.Milliseconds())
+
+	return res, nil
+}
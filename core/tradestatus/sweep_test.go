@@ -0,0 +1,67 @@
+package tradestatus
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusChanges(t *testing.T) {
+	whitelist := map[string]int{
+		"stay-active":   1,
+		"stay-inactive": 0,
+		"deregistered":  0,
+		"reregistered":  2,
+		"new-active":    1,
+		"new-inactive":  0,
+	}
+	snapshot := map[string]int{
+		"stay-active":   2,
+		"stay-inactive": 0,
+		"deregistered":  1,
+		"reregistered":  0,
+		"gone":          1,
+	}
+
+	voided, restored, removed := statusChanges(whitelist, snapshot, 1)
+	sort.Strings(voided)
+	sort.Strings(restored)
+
+	require.Equal(t, []string{"deregistered", "new-inactive"}, voided)
+	require.Equal(t, []string{"new-active", "reregistered"}, restored)
+	require.Equal(t, []string{"gone"}, removed)
+
+	voided, restored, removed = statusChanges(whitelist, whitelist, 1)
+	require.Empty(t, voided)
+	require.Empty(t, restored)
+	require.Empty(t, removed)
+}
+
+func TestLateVoids(t *testing.T) {
+	// "deregistered" went inactive at the last sweep and is already in the snapshot, a trade it made after
+	// that on a stale whitelist is only found by the late void
+	whitelist := map[string]int{
+		"active":       1,
+		"deregistered": 0,
+		"new-inactive": 0,
+	}
+	snapshot := map[string]int{
+		"active":       1,
+		"deregistered": 0,
+	}
+
+	voided, _, _ := statusChanges(whitelist, snapshot, 1)
+	require.Equal(t, []string{"new-inactive"}, voided)
+	require.Equal(t, []string{"deregistered"}, lateVoids(whitelist, voided, 1))
+
+	last := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	since := lateSince(last)
+	tradeAt := last.Add(time.Second)
+	require.False(t, tradeAt.Before(since))
+	require.True(t, since.Before(last))
+
+	// no sweep yet, every trade is looked at
+	require.True(t, lateSince(time.Time{}).IsZero())
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"expvar"
 	"net/http"
 	"os"
 	"os/signal"
@@ -34,15 +35,35 @@ func ServerRoute() *gin.Engine {
 
 	// http router
 	router.POST("/createtrade", handler.CreateTradde)
-	router.GET("/getusertrades", handler.GetUserTraddes)
-	router.GET("/getalltrades", handler.GetAllTraddes)
-	router.GET("/getregistertime", handler.GetRegisterTime)
-
-	router.GET("/getallevents", handler.GetAllEvents)
-	router.GET("/getlatestprice", handler.GetLatestPrice)
-
-	// WebSocket 路由
-	router.GET("/ws/getevents", handler.EventPublish)
+	router.POST("/createtrades", handler.CreateTrades)
+	router.POST("/validatetrade", handler.ClientRateLimit(), handler.ValidateTrade)
+	router.POST("/auth/challenge", handler.ClientRateLimit(), handler.AuthChallenge)
+	router.POST("/auth/login", handler.ClientRateLimit(), handler.AuthLogin)
+	router.POST("/auth/logout", handler.AuthLogout)
+
+	// signed routes, the roles allowed on each route are in the access policy
+	signed := router.Group("/", handler.SignedQuery(), handler.RequireRole())
+	signed.GET("/getusertrades", handler.GetUserTraddes)
+	signed.GET("/getalltrades", handler.GetAllTraddes)
+	signed.GET("/getregistertime", handler.GetRegisterTime)
+	signed.GET("/positions", handler.GetPositions)
+	signed.GET("/getminerscores", handler.GetMinerScores)
+	signed.GET("/quota", handler.GetQuota)
+	signed.GET("/getlatestprice", handler.GetLatestPrice)
+	signed.GET("/getallevents", handler.GetAllEvents)
+	signed.GET("/ws/getevents", handler.EventPublish)
+
+	// admin routes
+	signed.GET("/admin/whitelist", handler.GetWhitelist)
+	signed.POST("/admin/whitelist/add", handler.RequireSignedV2(), handler.ChangeWhitelist("add"))
+	signed.POST("/admin/whitelist/disable", handler.RequireSignedV2(), handler.ChangeWhitelist("disable"))
+	signed.POST("/admin/whitelist/enable", handler.RequireSignedV2(), handler.ChangeWhitelist("enable"))
+	signed.POST("/admin/whitelist/update", handler.RequireSignedV2(), handler.ChangeWhitelist("update"))
+	signed.POST("/admin/whitelist/release", handler.RequireSignedV2(), handler.ChangeWhitelist("release"))
+	signed.GET("/admin/pricejobs", handler.GetPriceJobs)
+	signed.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
+	router.GET("/tokens", handler.ClientRateLimit(), handler.RequireRole(), handler.GetTokens)
 
 	return router
 }
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRoutePolicies are the roles allowed on each route, the config overrides them route by route
+var defaultRoutePolicies = map[string][]string{
+	"/getusertrades":   {RoleMiner, RoleValidator, RoleAdmin},
+	"/getalltrades":    {RoleValidator, RoleAdmin},
+	"/getregistertime": {RoleValidator, RoleAdmin},
+	"/getminerscores":  {RoleValidator, RoleAdmin},
+	"/getallevents":    {RoleValidator, RoleAdmin},
+	"/ws/getevents":    {RoleValidator, RoleAdmin},
+	"/getlatestprice":  {RoleMiner, RoleValidator, RoleAdmin},
+	"/positions":       {RoleMiner, RoleValidator, RoleAdmin},
+	"/quota":           {RoleMiner, RoleValidator, RoleAdmin},
+	"/tokens":          {RolePublic},
+
+	"/admin/whitelist":         {RoleAdmin},
+	"/admin/whitelist/add":     {RoleAdmin},
+	"/admin/whitelist/disable": {RoleAdmin},
+	"/admin/whitelist/enable":  {RoleAdmin},
+	"/admin/whitelist/update":  {RoleAdmin},
+	"/admin/whitelist/release": {RoleAdmin},
+	"/admin/pricejobs":         {RoleAdmin},
+	"/debug/vars":              {RoleAdmin},
+}
+
+// the routes open without authentication, their callers have no role so the config can not restrict them
+var unauthenticatedRoutes = map[string]bool{
+	"/tokens": true,
+}
+
+// routeRoles return the roles allowed on the route, the routes without policy are admin only
+func routeRoles(route string) []string {
+	if roles, ok := config.GetAccessConfig().Routes[route]; ok && !unauthenticatedRoutes[route] {
+		return roles
+	}
+
+	if roles, ok := defaultRoutePolicies[route]; ok {
+		return roles
+	}
+
+	return []string{RoleAdmin}
+}
+
+func roleAllowed(roles []string, role string) bool {
+	for _, v := range roles {
+		if v == RolePublic || v == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequireRole enforce the route policy, it runs after the caller is authenticated and keeps its role for the handlers
+func RequireRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := &Response{
+			Code:    http.StatusOK,
+			Message: "success",
+		}
+
+		roles := routeRoles(c.FullPath())
+
+		// the session already carries the role
+		role := c.GetString(ctxRole)
+		if role == "" {
+			userIdStr, _ := authCaller(c)
+			if userIdStr != "" {
+				role = resolveRole(userIdStr)
+			}
+		}
+
+		if !roleAllowed(roles, role) {
+			if role == "" {
+				logger.Logrus.WithFields(logrus.Fields{"Path": c.FullPath()}).Error("RequireRole caller not registered")
+				r.Fail(ErrNotRegistered, "user not registered")
+			} else {
+				logger.Logrus.WithFields(logrus.Fields{"Path": c.FullPath(), "Role": role, "Roles": roles}).Error("RequireRole access denied")
+				r.Fail(ErrAccessDenied, fmt.Sprintf("%s has no access", role))
+			}
+
+			writeResponse(c, r)
+			c.Abort()
+			return
+		}
+
+		c.Set(ctxRole, role)
+		c.Next()
+	}
+}
+
+// callerRole return the role of the caller kept by RequireRole
+func callerRole(c *gin.Context) string {
+	return c.GetString(ctxRole)
+}
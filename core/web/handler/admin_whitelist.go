@@ -0,0 +1,318 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/tradestatus"
+	"github.com/Open0xScope/CommuneXService/core/whitelist"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+)
+
+const (
+	whitelistAdd     = "add"
+	whitelistDisable = "disable"
+	whitelistEnable  = "enable"
+	whitelistUpdate  = "update"
+	// hand the entry back to the chain sync
+	whitelistRelease = "release"
+)
+
+type OutWhitelistChange struct {
+	Action        string                   `json:"action"`
+	Before        *model.AdsMinerWhitelist `json:"before"`
+	After         *model.AdsMinerWhitelist `json:"after"`
+	VoidedTrade   int64                    `json:"voided_trades"`
+	RestoredTrade int64                    `json:"restored_trades"`
+}
+
+// whitelistChange compute the entry after the change from the one before, before is nil when the address is not whitelisted
+type whitelistChange func(before *model.AdsMinerWhitelist) (*model.AdsMinerWhitelist, string, string, error)
+
+func auditJSON(entry *model.AdsMinerWhitelist) string {
+	if entry == nil {
+		return ""
+	}
+
+	b, _ := json.Marshal(entry)
+	return string(b)
+}
+
+func getWhitelist(address string, status string) ([]model.AdsMinerWhitelist, error) {
+	res := make([]model.AdsMinerWhitelist, 0)
+
+	query := db.GetDB().NewSelect().Model(&res)
+	if address != "" {
+		query = query.Where("address = ?", address)
+	}
+	if status != "" {
+		s, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("status = ?", s)
+	}
+
+	err := query.Order("uid ASC").Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// changeWhitelist apply the change with its audit record in one transaction,
+// the trades of a miner losing its status are voided at once and restored when it gets it back.
+// The changed entry is locked against the chain sync until it is released
+func changeWhitelist(operator, action, address string, change whitelistChange) (*OutWhitelistChange, string, string, error) {
+	out := &OutWhitelistChange{Action: action}
+	errCode := ErrInternal
+	errmsg := "change whitelist failed"
+
+	err := db.GetDB().RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		var before model.AdsMinerWhitelist
+		err := tx.NewSelect().Model(&before).Where("address = ?", address).For("UPDATE").Scan(ctx)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			out.Before = &before
+		}
+
+		after, code, msg, err := change(out.Before)
+		if err != nil {
+			errCode, errmsg = code, msg
+			return err
+		}
+		after.Address = address
+		after.TimeStamp = time.Now().Unix()
+		after.AdminLocked = action != whitelistRelease
+		out.After = after
+
+		if out.Before == nil {
+			_, err = tx.NewInsert().Model(after).Exec(ctx)
+		} else {
+			_, err = tx.NewUpdate().Model(after).WherePK().Exec(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		minerStatus := config.GetAccessConfig().MinerStatus
+		wasActive := out.Before != nil && out.Before.Status >= minerStatus
+		if after.Status < minerStatus && (out.Before == nil || wasActive) {
+			out.VoidedTrade, err = tradestatus.VoidMiners(ctx, tx, []string{address}, tradestatus.ReasonAdminVoided)
+			if err != nil {
+				return err
+			}
+		}
+		if after.Status >= minerStatus && !wasActive {
+			out.RestoredTrade, err = tradestatus.RestoreMiners(ctx, tx, []string{address}, tradestatus.ReasonAdminEnabled, tradestatus.ReasonAdminVoided, tradestatus.ReasonDeregistered)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.NewInsert().Model(&model.AdsWhitelistAudit{
+			Address:   address,
+			Action:    action,
+			Operator:  operator,
+			Before:    auditJSON(out.Before),
+			After:     auditJSON(out.After),
+			CreatedAt: time.Now().UTC(),
+		}).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, errCode, errmsg, err
+	}
+
+	whitelist.Invalidate(address)
+
+	return out, "", fmt.Sprintf("%s whitelist success", action), nil
+}
+
+func parseOptionalInt(c *gin.Context, name string) (*int, error) {
+	str := c.Query(name)
+	if str == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+
+	return &v, nil
+}
+
+// whitelistChangeOf build the change of the admin action from the query parameters
+func whitelistChangeOf(c *gin.Context, action string) (whitelistChange, error) {
+	uid, err := parseOptionalInt(c, "uid")
+	if err != nil {
+		return nil, err
+	}
+
+	stake, err := parseOptionalInt(c, "stake")
+	if err != nil {
+		return nil, err
+	}
+
+	notFound := func() (*model.AdsMinerWhitelist, string, string, error) {
+		return nil, ErrNotFound, "address not in whitelist", errors.New("address not in whitelist")
+	}
+
+	switch action {
+	case whitelistAdd:
+		return func(before *model.AdsMinerWhitelist) (*model.AdsMinerWhitelist, string, string, error) {
+			if before != nil {
+				return nil, ErrAlreadyExists, "address already in whitelist", errors.New("address already in whitelist")
+			}
+
+			after := &model.AdsMinerWhitelist{Status: config.GetAccessConfig().MinerStatus}
+			if uid != nil {
+				after.UID = *uid
+			}
+			if stake != nil {
+				after.Stake = *stake
+			}
+			return after, "", "", nil
+		}, nil
+	case whitelistDisable, whitelistEnable:
+		return func(before *model.AdsMinerWhitelist) (*model.AdsMinerWhitelist, string, string, error) {
+			if before == nil {
+				return notFound()
+			}
+
+			after := *before
+			after.Status = 0
+			if action == whitelistEnable {
+				after.Status = config.GetAccessConfig().MinerStatus
+			}
+			return &after, "", "", nil
+		}, nil
+	case whitelistRelease:
+		return func(before *model.AdsMinerWhitelist) (*model.AdsMinerWhitelist, string, string, error) {
+			if before == nil {
+				return notFound()
+			}
+
+			after := *before
+			return &after, "", "", nil
+		}, nil
+	case whitelistUpdate:
+		if uid == nil && stake == nil {
+			return nil, errors.New("nothing to update, set uid or stake")
+		}
+
+		return func(before *model.AdsMinerWhitelist) (*model.AdsMinerWhitelist, string, string, error) {
+			if before == nil {
+				return notFound()
+			}
+
+			after := *before
+			if uid != nil {
+				after.UID = *uid
+			}
+			if stake != nil {
+				after.Stake = *stake
+			}
+			return &after, "", "", nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown action %s", action)
+}
+
+func GetWhitelist(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	userIdStr, _ := authCaller(c)
+	addressStr := c.Query("address")
+	statusStr := c.Query("status")
+
+	logger.Logrus.WithFields(logrus.Fields{"Operator": userIdStr, "Address": addressStr, "Status": statusStr}).Info("GetWhitelist info")
+
+	if addressStr != "" {
+		address, err := canonicalAddress(addressStr)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetWhitelist canonicalAddress failed")
+			r.Fail(ErrInvalidParams, "invalid address")
+			return
+		}
+		addressStr = address
+	}
+
+	result, err := getWhitelist(addressStr, statusStr)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetWhitelist getWhitelist failed")
+		r.Fail(ErrInternal, "get whitelist failed")
+		return
+	}
+
+	r.Message = "get whitelist success"
+	r.Data = result
+}
+
+// ChangeWhitelist handle the admin actions on the whitelist, the parameters are in the query so a v2 signature
+// covers them, the routes are behind RequireSignedV2
+func ChangeWhitelist(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := &Response{
+			Code:    http.StatusOK,
+			Message: "success",
+		}
+		defer func(r *Response) {
+			writeResponse(c, r)
+		}(r)
+
+		userIdStr, _ := authCaller(c)
+		addressStr := c.Query("address")
+
+		logger.Logrus.WithFields(logrus.Fields{"Operator": userIdStr, "Action": action, "Address": addressStr, "Uid": c.Query("uid"), "Stake": c.Query("stake")}).Info("ChangeWhitelist info")
+
+		address, err := canonicalAddress(addressStr)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("ChangeWhitelist canonicalAddress failed")
+			r.Fail(ErrInvalidParams, "invalid address")
+			return
+		}
+
+		change, err := whitelistChangeOf(c, action)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("ChangeWhitelist parse parmeter failed")
+			r.Fail(ErrInvalidParams, "invalid input parameters")
+			return
+		}
+
+		result, errCode, errmsg, err := changeWhitelist(userIdStr, action, address, change)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("ChangeWhitelist changeWhitelist failed")
+			r.Fail(errCode, errmsg)
+			return
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"Operator": userIdStr, "Result": result}).Info("ChangeWhitelist result")
+
+		r.Message = errmsg
+		r.Data = result
+	}
+}
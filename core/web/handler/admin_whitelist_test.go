@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisableValidatorRevokesAccess(t *testing.T) {
+	conf := config.AccessConfig{ValidatorStake: 1000, MinerStatus: 1}
+	validator := &model.AdsMinerWhitelist{Address: "validator", Stake: 5000, Status: 1}
+
+	isMiner, err := classifyEntry(validator, conf)
+	require.NoError(t, err)
+	require.False(t, isMiner)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/admin/whitelist/disable?address=validator", nil)
+	change, err := whitelistChangeOf(c, whitelistDisable)
+	require.NoError(t, err)
+
+	disabled, _, _, err := change(validator)
+	require.NoError(t, err)
+	require.Equal(t, validator.Stake, disabled.Stake)
+
+	_, err = classifyEntry(disabled, conf)
+	require.Error(t, err)
+
+	// a deregistered miner is neither
+	_, err = classifyEntry(&model.AdsMinerWhitelist{Address: "miner", Stake: 10, Status: 0}, conf)
+	require.Error(t, err)
+}
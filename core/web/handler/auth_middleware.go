@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/redis"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// the authenticated caller of a signed request
+const (
+	ctxUserID = "auth_user_id"
+	ctxPubKey = "auth_pub_key"
+	ctxRole   = "auth_role"
+)
+
+func replayKey(pubkey, timestamp string) string {
+	return fmt.Sprintf("query_sig_replay:%s:%s", pubkey, timestamp)
+}
+
+// checkFreshness reject the timestamps out of the clock skew window
+func checkFreshness(timeStr string, skew time.Duration) error {
+	ts, err := strconv.ParseInt(timeStr, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	diff := time.Since(time.Unix(ts, 0))
+	if diff > skew || diff < -skew {
+		return fmt.Errorf("timestamp %d out of the %v window", ts, skew)
+	}
+
+	return nil
+}
+
+// markUsed record the (pubkey, timestamp) pair, it is kept until the timestamp leaves the skew window
+func markUsed(pubkey, timestamp string, skew time.Duration) error {
+	ok, err := redis.GetRedisInst().SetNX(context.Background(), replayKey(pubkey, timestamp), 1, 2*skew).Result()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("signed request already used")
+	}
+
+	return nil
+}
+
+// verifySignedQuery check the freshness of the timestamp, the address of the pubkey of keyType, the signature
+// (v2 canonical message with sigver=2, the legacy one when it is still accepted) and the replay of the (pubkey, timestamp) pair
+func verifySignedQuery(c *gin.Context) (string, string, error) {
+	userIdStr := c.Query("userId")
+	pubKeyStr := c.Query("pubKey")
+	timeStr := c.Query("timestamp")
+	sigStr := c.Query("sig")
+
+	logger.Logrus.WithFields(logrus.Fields{"Path": c.FullPath(), "MinerID": userIdStr, "PubKey": pubKeyStr, "Timestamp": timeStr, "Signature": sigStr}).Info("SignedQuery info")
+
+	authConf := config.GetAuthConfig()
+	skew := authConf.ClockSkewDuration()
+
+	err := checkFreshness(timeStr, skew)
+	if err != nil {
+		return ErrTimestampInvalid, "request timestamp expired", err
+	}
+
+	var rawData string
+	if c.Query("sigver") == signVersion2 {
+		rawData = canonicalQueryMessage(c.Request.Method, c.Request.URL.Path, c.Request.URL.Query())
+	} else if authConf.LegacySign {
+		rawData = legacyQueryMessage(userIdStr, pubKeyStr, timeStr)
+	} else {
+		return ErrSignatureInvalid, "legacy signature not accepted, sign the request with sigver=2", errors.New("legacy signature not accepted")
+	}
+
+	keyType := c.Query("keyType")
+	err = CheckKeyAddress(keyType, pubKeyStr, userIdStr)
+	if err != nil {
+		return ErrAddressMismatch, "address and key not match", err
+	}
+
+	err = VerifyKeySign(keyType, rawData, pubKeyStr, sigStr)
+	if err != nil {
+		return ErrSignatureInvalid, "verify sig failed", err
+	}
+
+	err = markUsed(pubKeyStr, timeStr, skew)
+	if err != nil {
+		return ErrRequestReplayed, "signed request already used", err
+	}
+
+	minerID, err := canonicalAddress(userIdStr)
+	if err != nil {
+		return ErrAddressMismatch, "address and key not match", err
+	}
+
+	c.Set(ctxUserID, minerID)
+	c.Set(ctxPubKey, pubKeyStr)
+	return "", "", nil
+}
+
+// verifySession authenticate the request by its bearer token, the role is resolved again so a disabled
+// miner or a role change takes effect on the sessions at once
+func verifySession(c *gin.Context, token string) (string, string, error) {
+	session, err := getSession(token)
+	if err != nil {
+		return ErrSessionInvalid, "session not found or expired", err
+	}
+
+	role := resolveRole(session.MinerID)
+
+	logger.Logrus.WithFields(logrus.Fields{"Path": c.FullPath(), "MinerID": session.MinerID, "PubKey": session.PubKey, "Role": role, "LoginRole": session.Role}).Info("SignedQuery session info")
+
+	if role == "" {
+		return ErrNotRegistered, "user not registered", errors.New("session miner not registered")
+	}
+
+	c.Set(ctxUserID, session.MinerID)
+	c.Set(ctxPubKey, session.PubKey)
+	c.Set(ctxRole, role)
+	return "", "", nil
+}
+
+// SignedQuery authenticate the requests by a bearer token or by a signed query, then charge the query rate limit
+func SignedQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := &Response{
+			Code:    http.StatusOK,
+			Message: "success",
+		}
+
+		abort := func(errCode, msg string) {
+			r.Fail(errCode, msg)
+			writeResponse(c, r)
+			c.Abort()
+		}
+
+		var errCode, errmsg string
+		var err error
+		if token := bearerToken(c); token != "" {
+			errCode, errmsg, err = verifySession(c, token)
+		} else {
+			errCode, errmsg, err = verifySignedQuery(c)
+		}
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SignedQuery authenticate failed")
+			abort(errCode, errmsg)
+			return
+		}
+
+		userIdStr, pubKeyStr := authCaller(c)
+		quota, err := CheckQueryRateLimit(pubKeyStr, userIdStr, c.FullPath())
+		setRateLimitHeaders(c, quota)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SignedQuery CheckQueryRateLimit failed")
+			abort(ErrRateLimited, "access limit exceeded, please try again later")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ClientRateLimit charge the query rate limit of the routes open to unauthenticated callers
+func ClientRateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quota, err := CheckClientRateLimit(c.ClientIP(), c.FullPath())
+		setRateLimitHeaders(c, quota)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("ClientRateLimit CheckClientRateLimit failed")
+			r := &Response{Code: http.StatusOK, Message: "success"}
+			r.Fail(ErrRateLimited, "access limit exceeded, please try again later")
+			writeResponse(c, r)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSignedV2 let through only the requests signed with the v2 canonical message, it runs after SignedQuery.
+// The bearer sessions and the legacy signature do not cover the query parameters, so they can not authorize a write
+func RequireSignedV2() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var err error
+		if bearerToken(c) != "" {
+			err = errors.New("session not accepted")
+		} else if c.Query("sigver") != signVersion2 {
+			err = errors.New("legacy signature not accepted")
+		}
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Path": c.FullPath(), "ErrMsg": err}).Error("RequireSignedV2 failed")
+			r := &Response{Code: http.StatusOK, Message: "success"}
+			r.Fail(ErrSignatureInvalid, "sign the request with sigver=2")
+			writeResponse(c, r)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authCaller return the address and the pubkey authenticated by SignedQuery
+func authCaller(c *gin.Context) (string, string) {
+	return c.GetString(ctxUserID), c.GetString(ctxPubKey)
+}
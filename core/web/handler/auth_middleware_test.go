@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireSignedV2(t *testing.T) {
+	logger.Logrus = logrus.New()
+	logger.Logrus.SetOutput(io.Discard)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/whitelist/add", RequireSignedV2(), func(c *gin.Context) {
+		c.String(http.StatusOK, "changed")
+	})
+
+	cases := []struct {
+		name   string
+		query  string
+		bearer string
+		pass   bool
+	}{
+		{"v2 signature", "?sigver=2&address=5F", "", true},
+		{"legacy signature", "?address=5F", "", false},
+		{"other version", "?sigver=1&address=5F", "", false},
+		{"bearer session", "?sigver=2&address=5F", "token", false},
+	}
+
+	for _, v := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/admin/whitelist/add"+v.query, nil)
+		if v.bearer != "" {
+			req.Header.Set("Authorization", bearerPrefix+v.bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, v.pass, w.Body.String() == "changed", v.name)
+	}
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/redis"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// domain separation of the login message
+	loginDomain = "CommuneX-Auth-Login"
+
+	bearerPrefix = "Bearer "
+)
+
+type InAuthChallenge struct {
+	MinerID string `json:"miner_id"`
+	PubKey  string `json:"pub_key"`
+	KeyType string `json:"key_type"`
+}
+
+type OutAuthChallenge struct {
+	Challenge string `json:"challenge"`
+	Message   string `json:"message"`
+	ExpireAt  int64  `json:"expire_at"`
+}
+
+type InAuthLogin struct {
+	MinerID   string `json:"miner_id"`
+	PubKey    string `json:"pub_key"`
+	Challenge string `json:"challenge"`
+	Signature string `json:"signature"`
+	KeyType   string `json:"key_type"`
+}
+
+type OutAuthLogin struct {
+	Token    string `json:"token"`
+	Role     string `json:"role"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+// authSession is the caller authenticated by a login, it is kept in redis until it expires or logs out.
+// Role is the role at login, the requests use the current role of the miner
+type authSession struct {
+	MinerID string `json:"miner_id"`
+	PubKey  string `json:"pub_key"`
+	Role    string `json:"role"`
+}
+
+// a challenge is kept by its own value, the pubkey it was issued to is the value
+func challengeKey(challenge string) string {
+	return fmt.Sprintf("auth_challenge:%s", challenge)
+}
+
+func sessionKey(token string) string {
+	return fmt.Sprintf("auth_session:%s", token)
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// loginMessage is the message signed to answer the challenge
+func loginMessage(minerID, pubkey, challenge string) string {
+	return fmt.Sprintf("%s\n%s\n%s\n%s", loginDomain, minerID, pubkey, challenge)
+}
+
+// bearerToken return the token of the Authorization header, empty when there is none
+func bearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(auth, bearerPrefix))
+}
+
+func getSession(token string) (*authSession, error) {
+	value, err := redis.GetRedisInst().Get(context.Background(), sessionKey(token)).Result()
+	if err == goredis.Nil {
+		return nil, errors.New("session not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session authSession
+	err = json.Unmarshal([]byte(value), &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func createChallenge(in *InAuthChallenge) (*OutAuthChallenge, string, string, error) {
+	err := CheckKeyAddress(in.KeyType, in.PubKey, in.MinerID)
+	if err != nil {
+		return nil, ErrAddressMismatch, "address and pubkey not match", err
+	}
+
+	minerID, err := canonicalAddress(in.MinerID)
+	if err != nil {
+		return nil, ErrInvalidParams, "invalid miner address", err
+	}
+
+	if resolveRole(minerID) == "" {
+		return nil, ErrNotRegistered, "user not registered", errors.New("user not registered")
+	}
+
+	challenge, err := randomHex(32)
+	if err != nil {
+		return nil, ErrInternal, "create challenge failed", err
+	}
+
+	ttl := config.GetAuthConfig().ChallengeTTLDuration()
+	err = redis.GetRedisInst().Set(context.Background(), challengeKey(challenge), in.PubKey, ttl).Err()
+	if err != nil {
+		return nil, ErrInternal, "create challenge failed", err
+	}
+
+	return &OutAuthChallenge{
+		Challenge: challenge,
+		Message:   loginMessage(in.MinerID, in.PubKey, challenge),
+		ExpireAt:  time.Now().Add(ttl).Unix(),
+	}, "", "create challenge success", nil
+}
+
+func login(in *InAuthLogin) (*OutAuthLogin, string, string, error) {
+	ctx := context.Background()
+
+	pubkey, err := redis.GetRedisInst().Get(ctx, challengeKey(in.Challenge)).Result()
+	if err == goredis.Nil || (err == nil && pubkey != in.PubKey) {
+		return nil, ErrSignatureInvalid, "challenge not found or expired", errors.New("challenge not found or expired")
+	}
+	if err != nil {
+		return nil, ErrInternal, "get challenge failed", err
+	}
+
+	err = CheckKeyAddress(in.KeyType, in.PubKey, in.MinerID)
+	if err != nil {
+		return nil, ErrAddressMismatch, "address and pubkey not match", err
+	}
+
+	err = VerifyKeySign(in.KeyType, loginMessage(in.MinerID, in.PubKey, in.Challenge), in.PubKey, in.Signature)
+	if err != nil {
+		return nil, ErrSignatureInvalid, "verify sig failed", err
+	}
+
+	// a challenge is answered once, only its signer can consume it
+	deleted, err := redis.GetRedisInst().Del(ctx, challengeKey(in.Challenge)).Result()
+	if err != nil {
+		return nil, ErrInternal, "consume challenge failed", err
+	}
+	if deleted == 0 {
+		return nil, ErrSignatureInvalid, "challenge not found or expired", errors.New("challenge already answered")
+	}
+
+	minerID, err := canonicalAddress(in.MinerID)
+	if err != nil {
+		return nil, ErrInvalidParams, "invalid miner address", err
+	}
+
+	role := resolveRole(minerID)
+	if role == "" {
+		return nil, ErrNotRegistered, "user not registered", errors.New("user not registered")
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, ErrInternal, "create session failed", err
+	}
+
+	value, err := json.Marshal(authSession{MinerID: minerID, PubKey: in.PubKey, Role: role})
+	if err != nil {
+		return nil, ErrInternal, "create session failed", err
+	}
+
+	ttl := config.GetAuthConfig().SessionTTLDuration()
+	err = redis.GetRedisInst().Set(ctx, sessionKey(token), value, ttl).Err()
+	if err != nil {
+		return nil, ErrInternal, "create session failed", err
+	}
+
+	return &OutAuthLogin{
+		Token:    token,
+		Role:     role,
+		ExpireAt: time.Now().Add(ttl).Unix(),
+	}, "", "login success", nil
+}
+
+func AuthChallenge(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	var in = InAuthChallenge{}
+	err := c.ShouldBindJSON(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("AuthChallenge parse parmeter failed")
+		r.Fail(ErrInvalidParams, "invalid input parameters")
+		return
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": in.MinerID, "PubKey": in.PubKey}).Info("AuthChallenge info")
+
+	result, errCode, errmsg, err := createChallenge(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("AuthChallenge createChallenge failed")
+		r.Fail(errCode, errmsg)
+		return
+	}
+
+	r.Message = errmsg
+	r.Data = result
+}
+
+func AuthLogin(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	var in = InAuthLogin{}
+	err := c.ShouldBindJSON(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("AuthLogin parse parmeter failed")
+		r.Fail(ErrInvalidParams, "invalid input parameters")
+		return
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": in.MinerID, "PubKey": in.PubKey}).Info("AuthLogin info")
+
+	result, errCode, errmsg, err := login(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("AuthLogin login failed")
+		r.Fail(errCode, errmsg)
+		return
+	}
+
+	r.Message = errmsg
+	r.Data = result
+}
+
+// AuthLogout revoke the bearer token of the request
+func AuthLogout(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	token := bearerToken(c)
+	if token == "" {
+		r.Fail(ErrSessionInvalid, "missing bearer token")
+		return
+	}
+
+	deleted, err := redis.GetRedisInst().Del(context.Background(), sessionKey(token)).Result()
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("AuthLogout delete session failed")
+		r.Fail(ErrInternal, "logout failed")
+		return
+	}
+
+	if deleted == 0 {
+		r.Fail(ErrSessionInvalid, "session not found or expired")
+		return
+	}
+
+	r.Message = "logout success"
+}
@@ -1,7 +1,87 @@
 package handler
 
+import (
+	"net/http"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/gin-gonic/gin"
+)
+
 type Response struct {
 	Code    int64       `json:"code"`
+	ErrCode string      `json:"err_code,omitempty"`
 	Message string      `json:"msg"`
 	Data    interface{} `json:"data"`
 }
+
+// stable error codes, clients should match them instead of the message
+const (
+	ErrInvalidParams          = "INVALID_PARAMS"
+	ErrSignatureInvalid       = "SIGNATURE_INVALID"
+	ErrRequestReplayed        = "REQUEST_REPLAYED"
+	ErrSessionInvalid         = "SESSION_INVALID"
+	ErrAddressMismatch        = "ADDRESS_MISMATCH"
+	ErrNotRegistered          = "NOT_REGISTERED"
+	ErrAccessDenied           = "ACCESS_DENIED"
+	ErrRateLimited            = "RATE_LIMITED"
+	ErrTokenNotTradable       = "TOKEN_NOT_TRADABLE"
+	ErrLeverageOutOfRange     = "LEVERAGE_OUT_OF_RANGE"
+	ErrLeverageInvalidStep    = "LEVERAGE_INVALID_STEP"
+	ErrLeverageIncreaseLocked = "LEVERAGE_INCREASE_LOCKED"
+	ErrPositionManagerInvalid = "POSITION_MANAGER_INVALID"
+	ErrPositionConflict       = "POSITION_CONFLICT"
+	ErrNonceInvalid           = "NONCE_INVALID"
+	ErrTimestampInvalid       = "TIMESTAMP_INVALID"
+	ErrPriceUnavailable       = "PRICE_UNAVAILABLE"
+	ErrNotFound               = "NOT_FOUND"
+	ErrAlreadyExists          = "ALREADY_EXISTS"
+	ErrInternal               = "INTERNAL_ERROR"
+)
+
+var errCodeStatus = map[string]int{
+	ErrInvalidParams:          http.StatusBadRequest,
+	ErrSignatureInvalid:       http.StatusUnauthorized,
+	ErrRequestReplayed:        http.StatusUnauthorized,
+	ErrSessionInvalid:         http.StatusUnauthorized,
+	ErrAddressMismatch:        http.StatusUnauthorized,
+	ErrNotRegistered:          http.StatusForbidden,
+	ErrAccessDenied:           http.StatusForbidden,
+	ErrRateLimited:            http.StatusTooManyRequests,
+	ErrTokenNotTradable:       http.StatusUnprocessableEntity,
+	ErrLeverageOutOfRange:     http.StatusUnprocessableEntity,
+	ErrLeverageInvalidStep:    http.StatusUnprocessableEntity,
+	ErrLeverageIncreaseLocked: http.StatusUnprocessableEntity,
+	ErrPositionManagerInvalid: http.StatusUnprocessableEntity,
+	ErrPositionConflict:       http.StatusConflict,
+	ErrNonceInvalid:           http.StatusConflict,
+	ErrTimestampInvalid:       http.StatusUnprocessableEntity,
+	ErrPriceUnavailable:       http.StatusUnprocessableEntity,
+	ErrNotFound:               http.StatusNotFound,
+	ErrAlreadyExists:          http.StatusConflict,
+	ErrInternal:               http.StatusInternalServerError,
+}
+
+func errStatus(errCode string) int {
+	if status, ok := errCodeStatus[errCode]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Fail set the error code, the status code matching it and the message
+func (r *Response) Fail(errCode, msg string) {
+	r.Code = int64(errStatus(errCode))
+	r.ErrCode = errCode
+	r.Message = msg
+}
+
+// writeResponse write the response with its status code, the legacy clients always get 200
+func writeResponse(c *gin.Context, r *Response) {
+	status := http.StatusOK
+	if !config.GetResponseConfig().LegacyStatus && r.Code >= http.StatusBadRequest {
+		status = int(r.Code)
+	}
+
+	c.JSON(status, r)
+}
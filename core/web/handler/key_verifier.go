@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/ChainSafe/gossamer/lib/crypto/ed25519"
+	"github.com/ChainSafe/gossamer/lib/crypto/secp256k1"
+	"github.com/ChainSafe/gossamer/lib/crypto/sr25519"
+)
+
+const (
+	KeyTypeSr25519 = "sr25519"
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeEcdsa   = "ecdsa"
+)
+
+// keyVerifier check the signatures of one key type and derive the account id of its public key
+type keyVerifier interface {
+	verify(pubkey, msg, sig []byte) error
+	accountID(pubkey []byte) ([]byte, error)
+}
+
+var keyVerifiers = map[string]keyVerifier{
+	KeyTypeSr25519: sr25519Verifier{},
+	KeyTypeEd25519: ed25519Verifier{},
+	KeyTypeEcdsa:   ecdsaVerifier{},
+}
+
+// getKeyVerifier return the verifier of the key type, the requests without key type are sr25519
+func getKeyVerifier(keyType string) (keyVerifier, error) {
+	if keyType == "" {
+		keyType = KeyTypeSr25519
+	}
+
+	v, ok := keyVerifiers[keyType]
+	if !ok {
+		return nil, fmt.Errorf("key type %s is not supported", keyType)
+	}
+
+	return v, nil
+}
+
+type sr25519Verifier struct{}
+
+func (sr25519Verifier) verify(pubkey, msg, sig []byte) error {
+	pub, err := sr25519.NewPublicKey(pubkey)
+	if err != nil {
+		return err
+	}
+
+	ok, err := pub.Verify(msg, sig)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("failed to verify signature")
+	}
+
+	return nil
+}
+
+func (sr25519Verifier) accountID(pubkey []byte) ([]byte, error) {
+	pub, err := sr25519.NewPublicKey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pub.Encode(), nil
+}
+
+type ed25519Verifier struct{}
+
+func (ed25519Verifier) verify(pubkey, msg, sig []byte) error {
+	pub, err := ed25519.NewPublicKey(pubkey)
+	if err != nil {
+		return err
+	}
+
+	ok, err := pub.Verify(msg, sig)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("failed to verify signature")
+	}
+
+	return nil
+}
+
+func (ed25519Verifier) accountID(pubkey []byte) ([]byte, error) {
+	pub, err := ed25519.NewPublicKey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pub.Encode(), nil
+}
+
+// ecdsaVerifier check the secp256k1 signatures of the ethereum tooling (personal_sign of the message),
+// the account id is the blake2b-256 of the compressed public key like substrate
+type ecdsaVerifier struct{}
+
+func compressEcdsaKey(pubkey []byte) ([]byte, error) {
+	var pub secp256k1.PublicKey
+	var err error
+
+	if len(pubkey) == 65 {
+		err = pub.UnmarshalPubkey(pubkey)
+	} else {
+		err = pub.Decode(pubkey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pub.Encode(), nil
+}
+
+func personalMessageHash(msg []byte) ([]byte, error) {
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(msg), msg)
+	hash, err := common.Keccak256([]byte(prefixed))
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.ToBytes(), nil
+}
+
+func (ecdsaVerifier) verify(pubkey, msg, sig []byte) error {
+	pub, err := compressEcdsaKey(pubkey)
+	if err != nil {
+		return err
+	}
+
+	// the recovery byte is not needed to verify
+	if len(sig) == secp256k1.SignatureLengthRecovery {
+		sig = sig[:secp256k1.SignatureLength]
+	}
+	if len(sig) != secp256k1.SignatureLength {
+		return errors.New("invalid signature length")
+	}
+
+	hash, err := personalMessageHash(msg)
+	if err != nil {
+		return err
+	}
+
+	return secp256k1.VerifySignature(pub, sig, hash)
+}
+
+func (ecdsaVerifier) accountID(pubkey []byte) ([]byte, error) {
+	pub, err := compressEcdsaKey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := common.Blake2bHash(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.ToBytes(), nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ChainSafe/gossamer/lib/crypto/ed25519"
+	"github.com/ChainSafe/gossamer/lib/crypto/secp256k1"
+	"github.com/ChainSafe/gossamer/lib/crypto/sr25519"
+	"github.com/Open0xScope/CommuneXService/core/ss58"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyKeySign(t *testing.T) {
+	msg := "5Fminer0xtoken"
+
+	srKp, err := sr25519.GenerateKeypair()
+	require.NoError(t, err)
+	srSig, err := srKp.Sign([]byte(msg))
+	require.NoError(t, err)
+
+	edKp, err := ed25519.GenerateKeypair()
+	require.NoError(t, err)
+	edSig, err := edKp.Sign([]byte(msg))
+	require.NoError(t, err)
+
+	ecKp, err := secp256k1.GenerateKeypair()
+	require.NoError(t, err)
+	hash, err := personalMessageHash([]byte(msg))
+	require.NoError(t, err)
+	ecSig, err := ecKp.Sign(hash)
+	require.NoError(t, err)
+
+	cases := []struct {
+		keyType string
+		pubkey  []byte
+		sig     []byte
+	}{
+		{"", srKp.Public().Encode(), srSig},
+		{KeyTypeSr25519, srKp.Public().Encode(), srSig},
+		{KeyTypeEd25519, edKp.Public().Encode(), edSig},
+		{KeyTypeEcdsa, ecKp.Public().Encode(), ecSig},
+	}
+
+	for _, c := range cases {
+		pubkey := hex.EncodeToString(c.pubkey)
+		sig := hex.EncodeToString(c.sig)
+
+		require.NoError(t, VerifyKeySign(c.keyType, msg, pubkey, sig), c.keyType)
+		require.Error(t, VerifyKeySign(c.keyType, msg+"1", pubkey, sig), c.keyType)
+	}
+
+	// a key type does not verify the signatures of another one
+	require.Error(t, VerifyKeySign(KeyTypeSr25519, msg, hex.EncodeToString(edKp.Public().Encode()), hex.EncodeToString(edSig)))
+	require.Error(t, VerifyKeySign("rsa", msg, hex.EncodeToString(edKp.Public().Encode()), hex.EncodeToString(edSig)))
+}
+
+func TestCheckKeyAddress(t *testing.T) {
+	srKp, err := sr25519.GenerateKeypair()
+	require.NoError(t, err)
+	srPub := hex.EncodeToString(srKp.Public().Encode())
+	require.NoError(t, CheckKeyAddress(KeyTypeSr25519, srPub, string(srKp.Public().Address())))
+
+	edKp, err := ed25519.GenerateKeypair()
+	require.NoError(t, err)
+	edPub := hex.EncodeToString(edKp.Public().Encode())
+	require.NoError(t, CheckKeyAddress(KeyTypeEd25519, edPub, string(edKp.Public().Address())))
+	require.Error(t, CheckKeyAddress(KeyTypeEd25519, edPub, string(srKp.Public().Address())))
+
+	// the ecdsa account is the blake2b-256 of the compressed key, the uncompressed key has the same address
+	ecKp, err := secp256k1.GenerateKeypair()
+	require.NoError(t, err)
+	accountID, err := ecdsaVerifier{}.accountID(ecKp.Public().Encode())
+	require.NoError(t, err)
+	require.Len(t, accountID, 32)
+
+	addr := ss58.Encode(ss58.DefaultPrefix, accountID)
+	require.NoError(t, CheckKeyAddress(KeyTypeEcdsa, hex.EncodeToString(ecKp.Public().Encode()), addr))
+}
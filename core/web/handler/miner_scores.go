@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/scoring"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type WindowScores struct {
+	WindowDays int64                `json:"window_days"`
+	Since      int64                `json:"since"`
+	Scores     []scoring.MinerScore `json:"scores"`
+}
+
+func getScoreTrades(minerID string, since int64) ([]model.AdsTokenTrade, error) {
+	ctx := context.Background()
+	res := make([]model.AdsTokenTrade, 0)
+
+	query := db.GetDB().NewSelect().Model(&res).Column("miner_id", "nonce", "token", "position_manager", "direction", "timestamp", "price", "price_4h", "leverage").Where("status > 0 and timestamp >= ?", since)
+	if minerID != "" {
+		query = query.Where("miner_id = ?", minerID)
+	}
+
+	err := query.Order("timestamp ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func parseScoreWindows(windowStr string) ([]int64, error) {
+	windows := config.GetScoringConfig().Windows
+	if windowStr == "" {
+		return windows, nil
+	}
+
+	w, err := strconv.ParseInt(windowStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range windows {
+		if v == w {
+			return []int64{w}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("window %d days is not supported", w)
+}
+
+func getMinerScores(windows []int64, minerID string) ([]WindowScores, error) {
+	maxWindow := int64(0)
+	for _, v := range windows {
+		if v > maxWindow {
+			maxWindow = v
+		}
+	}
+	if maxWindow <= 0 {
+		return nil, errors.New("no valid score window")
+	}
+
+	now := time.Now().Unix()
+	trades, err := getScoreTrades(minerID, now-maxWindow*24*3600)
+	if err != nil {
+		return nil, err
+	}
+
+	results := scoring.PairTrades(trades)
+
+	res := make([]WindowScores, 0, len(windows))
+	for _, v := range windows {
+		since := now - v*24*3600
+		res = append(res, WindowScores{
+			WindowDays: v,
+			Since:      since,
+			Scores:     scoring.Score(results, since),
+		})
+	}
+
+	return res, nil
+}
+
+func GetMinerScores(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	userIdStr, pubKeyStr := authCaller(c)
+
+	windowStr := c.Query("window")
+	minerStr := c.Query("miner")
+
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "Window": windowStr, "Miner": minerStr}).Info("GetMinerScores info")
+
+	if minerStr != "" {
+		miner, err := canonicalAddress(minerStr)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetMinerScores canonicalAddress failed")
+			r.Fail(ErrInvalidParams, "invalid miner address")
+			return
+		}
+		minerStr = miner
+	}
+
+	windows, err := parseScoreWindows(windowStr)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetMinerScores parseScoreWindows failed")
+		r.Fail(ErrInvalidParams, "invalid score window")
+		return
+	}
+
+	result, err := getMinerScores(windows, minerStr)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetMinerScores getMinerScores failed")
+		r.Fail(ErrInternal, "get miner scores failed")
+		return
+	}
+
+	r.Message = "get miner scores success"
+	r.Data = result
+}
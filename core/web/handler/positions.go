@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/core/position"
+	"github.com/Open0xScope/CommuneXService/core/scoring"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type OutPosition struct {
+	MinerID       string    `json:"miner_id"`
+	Token         string    `json:"token"`
+	Status        string    `json:"status"`
+	Direction     int       `json:"direction"`
+	Leverage      float64   `json:"leverage"`
+	EntryPrice    float64   `json:"entry_price"`
+	OpenNonce     int64     `json:"open_nonce"`
+	OpenTimestamp int64     `json:"open_timestamp"`
+	MarkPrice     float64   `json:"mark_price"`
+	RealizedPnl   float64   `json:"realized_pnl"`
+	UnrealizedPnl float64   `json:"unrealized_pnl"`
+	ClosedCount   int64     `json:"closed_count"`
+	UpdatedAt     time.Time `json:"update_at"`
+}
+
+func getPositions(minerID, token string, openOnly bool, page, limit int) ([]OutPosition, error) {
+	positions, err := position.GetPositions(minerID, token, openOnly, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	prices, err := getLatestPrice("")
+	if err != nil {
+		return nil, err
+	}
+
+	markPrices := make(map[string]float64, len(prices))
+	for _, v := range prices {
+		markPrices[v.TokenAddress] = v.Price
+	}
+
+	res := make([]OutPosition, 0, len(positions))
+	for _, v := range positions {
+		item := OutPosition{
+			MinerID:     v.MinerID,
+			Token:       v.TokenAddress,
+			Status:      "flat",
+			RealizedPnl: v.RealizedPnl,
+			ClosedCount: v.ClosedCount,
+			UpdatedAt:   v.UpdatedAt,
+		}
+
+		if v.Status == position.StatusOpen {
+			item.Status = "open"
+			item.Direction = v.Direction
+			item.Leverage = v.Leverage
+			item.EntryPrice = v.EntryPrice
+			item.OpenNonce = v.OpenNonce
+			item.OpenTimestamp = v.OpenTimestamp
+			item.MarkPrice = markPrices[v.TokenAddress]
+			item.UnrealizedPnl = scoring.TradeReturn(v.Direction, v.Leverage, v.EntryPrice, item.MarkPrice)
+		}
+
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// parsePage parse the optional page and limit, zero when they are not set
+func parsePage(pageStr, limitStr string) (int, int, error) {
+	page, limit := 0, 0
+	var err error
+	if pageStr != "" {
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page %s", pageStr)
+		}
+	}
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > position.MaxPageSize {
+			return 0, 0, fmt.Errorf("invalid limit %s", limitStr)
+		}
+	}
+
+	return page, limit, nil
+}
+
+func GetPositions(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	userIdStr, pubKeyStr := authCaller(c)
+
+	minerStr := c.Query("miner")
+	tokenStr := c.Query("token")
+	openOnly := c.Query("open") == "1"
+	pageStr := c.Query("page")
+	limitStr := c.Query("limit")
+
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "Miner": minerStr, "Token": tokenStr, "Page": pageStr, "Limit": limitStr}).Info("GetPositions info")
+
+	// a page is at most position.MaxPageSize positions, the next pages are read with page
+	page, limit, err := parsePage(pageStr, limitStr)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetPositions parse page failed")
+		r.Fail(ErrInvalidParams, "invalid page or limit")
+		return
+	}
+
+	if minerStr != "" {
+		miner, err := canonicalAddress(minerStr)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetPositions canonicalAddress failed")
+			r.Fail(ErrInvalidParams, "invalid miner address")
+			return
+		}
+		minerStr = miner
+	}
+
+	// miners can only see their own positions
+	if callerRole(c) == RoleMiner {
+		if minerStr != "" && minerStr != userIdStr {
+			logger.Logrus.WithFields(logrus.Fields{"Miner": minerStr}).Error("GetPositions miner has no access to other positions")
+			r.Fail(ErrAccessDenied, "miner has no access")
+			return
+		}
+		minerStr = userIdStr
+	}
+
+	result, err := getPositions(minerStr, tokenStr, openOnly, page, limit)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetPositions getPositions failed")
+		r.Fail(ErrInternal, "get positions failed")
+		return
+	}
+
+	r.Message = "get positions success"
+	r.Data = result
+}
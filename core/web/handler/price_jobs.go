@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/pricejob"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+)
+
+const maxPriceJobsLimit = 1000
+
+type OutPriceJobs struct {
+	Counts map[string]int      `json:"counts"`
+	Jobs   []model.AdsPriceJob `json:"jobs"`
+}
+
+func countPriceJobs(ctx context.Context) (map[string]int, error) {
+	var rows []struct {
+		Status string `bun:"status"`
+		Count  int    `bun:"count"`
+	}
+
+	err := db.GetDB().NewSelect().Model((*model.AdsPriceJob)(nil)).
+		Column("status").ColumnExpr("count(*) AS count").Group("status").Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]int{pricejob.StatusPending: 0, pricejob.StatusDone: 0, pricejob.StatusDead: 0}
+	for _, v := range rows {
+		res[v.Status] = v.Count
+	}
+
+	return res, nil
+}
+
+// getPriceJobs get the jobs of the status, the pending and dead ones when it is empty, the next due first
+func getPriceJobs(status, token, minerId, pageStr, limitStr string) (*OutPriceJobs, error) {
+	ctx := context.Background()
+
+	counts, err := countPriceJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, _ := strconv.ParseInt(pageStr, 10, 64)
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+	if limit < 1 || limit > maxPriceJobsLimit {
+		limit = maxPriceJobsLimit
+	}
+
+	statuses := []string{pricejob.StatusPending, pricejob.StatusDead}
+	if status != "" {
+		statuses = []string{status}
+	}
+
+	jobs := make([]model.AdsPriceJob, 0)
+	query := db.GetDB().NewSelect().Model(&jobs).Where("status IN (?)", bun.In(statuses))
+	if token != "" {
+		query = query.Where("token = ?", token)
+	}
+	if minerId != "" {
+		query = query.Where("miner_id = ?", minerId)
+	}
+
+	err = query.Order("due_at ASC").Limit(int(limit)).Offset(int((page - 1) * limit)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutPriceJobs{Counts: counts, Jobs: jobs}, nil
+}
+
+func GetPriceJobs(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	userIdStr, _ := authCaller(c)
+	statusStr := c.Query("status")
+	tokenStr := c.Query("token")
+	minerStr := c.Query("minerid")
+	page := c.Query("page")
+	limit := c.Query("limit")
+
+	logger.Logrus.WithFields(logrus.Fields{"Operator": userIdStr, "Status": statusStr, "Token": tokenStr, "MinerID": minerStr, "Page": page, "Limit": limit}).Info("GetPriceJobs info")
+
+	switch statusStr {
+	case "", pricejob.StatusPending, pricejob.StatusDone, pricejob.StatusDead:
+	default:
+		r.Fail(ErrInvalidParams, "invalid status")
+		return
+	}
+
+	if minerStr != "" {
+		address, err := canonicalAddress(minerStr)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetPriceJobs canonicalAddress failed")
+			r.Fail(ErrInvalidParams, "invalid minerid")
+			return
+		}
+		minerStr = address
+	}
+
+	result, err := getPriceJobs(statusStr, tokenStr, minerStr, page, limit)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetPriceJobs getPriceJobs failed")
+		r.Fail(ErrInternal, "get price jobs failed")
+		return
+	}
+
+	r.Message = "get price jobs success"
+	r.Data = result
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -24,7 +23,7 @@ func getTokenPrice(token string, timestamp int64) (*model.ChainTokenPrice, error
 		Table("crawler_ods.ods_crawler_coingecko_trade_token_price").
 		Column("*").
 		ColumnExpr("row_number() OVER (PARTITION BY token_address ORDER BY pt DESC) AS rn").
-		Where("chain IN (?)", bun.In(ChainList)).
+		Where("chain IN (?)", bun.In(ChainList())).
 		Where("token_address = ?", token).
 		Where("pt <= ?", mathtime)
 
@@ -58,8 +57,8 @@ func getLatestPrice(timestr string) ([]model.ChainTokenPrice, error) {
 		Table("crawler_ods.ods_crawler_coingecko_trade_token_price").
 		Column("*").
 		ColumnExpr("row_number() OVER (PARTITION BY token_address ORDER BY pt DESC) AS rn").
-		Where("chain IN (?)", bun.In(ChainList)).
-		Where("token_address IN (?)", bun.In(TokenList)).
+		Where("chain IN (?)", bun.In(ChainList())).
+		Where("token_address IN (?)", bun.In(TokenList())).
 		Where("pt <= ?", mathtime)
 
 	// Build the main query
@@ -80,40 +79,19 @@ func GetLatestPrice(c *gin.Context) {
 		Message: "success",
 	}
 	defer func(r *Response) {
-		c.JSON(http.StatusOK, r)
+		writeResponse(c, r)
 	}(r)
 
-	userIdStr := c.Query("userId")
-	pubKeyStr := c.Query("pubKey")
-	timeStr := c.Query("timestamp")
-	sigStr := c.Query("sig")
+	userIdStr, pubKeyStr := authCaller(c)
 
 	latestStr := c.Query("latesttime")
 
-	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "Timestamp": timeStr, "Signature": sigStr, "LatestTime": latestStr}).Info("GetLatestPrice info")
-
-	rawData := fmt.Sprintf("%s%s%s", userIdStr, pubKeyStr, timeStr)
-	err := VerifySign(rawData, pubKeyStr, sigStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetLatestPrice VerifySign failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "verify sig failed"
-		return
-	}
-
-	err = CheckQueryRateLimit(pubKeyStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetLatestPrice CheckQueryRateLimit failed")
-		r.Code = http.StatusTooManyRequests
-		r.Message = "access limit exceeded, please try again later"
-		return
-	}
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "LatestTime": latestStr}).Info("GetLatestPrice info")
 
 	result, err := getLatestPrice(latestStr)
 	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetLatestPrice getLatestPrice failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "get latest price failed"
+		r.Fail(ErrInternal, "get latest price failed")
 		return
 	}
 
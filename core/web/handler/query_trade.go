@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"strconv"
 
@@ -13,10 +12,14 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// priceMarksExpr select the price marks of each trade as a json object of the prices by horizon seconds
+const priceMarksExpr = `(SELECT json_object_agg(m.horizon, m.price) FROM trade_price_marks AS m
+	WHERE m.miner_id = oat.miner_id AND m.token = oat.token AND m.nonce = oat.nonce AND m.timestamp = oat.timestamp) AS price_marks`
+
 func getUserTrades(userId string) ([]model.AdsTokenTrade, error) {
 	ctx := context.Background()
 	res := make([]model.AdsTokenTrade, 0)
-	err := db.GetDB().NewSelect().Model(&res).Where("miner_id = ?", userId).Order("timestamp DESC").Limit(1000).Scan(ctx)
+	err := db.GetDB().NewSelect().Model(&res).ColumnExpr("oat.*").ColumnExpr(priceMarksExpr).Where("miner_id = ?", userId).Order("timestamp DESC").Limit(1000).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +27,8 @@ func getUserTrades(userId string) ([]model.AdsTokenTrade, error) {
 	return res, nil
 }
 
-func getAllTrades(times, pageStr, limitStr string) ([]model.ResTokenTrade, error) {
+// getAllTrades get the trades since times, the excluded trades with their status reason only when includeExcluded
+func getAllTrades(times, pageStr, limitStr string, includeExcluded bool) ([]model.ResTokenTrade, error) {
 	ctx := context.Background()
 	res := make([]model.ResTokenTrade, 0)
 
@@ -55,7 +59,12 @@ func getAllTrades(times, pageStr, limitStr string) ([]model.ResTokenTrade, error
 
 	offset := (page - 1) * limit
 
-	err := db.GetDB().NewSelect().Model(&res).Column("miner_id", "nonce", "token", "position_manager", "direction", "timestamp", "price", "price_4h", "leverage", "create_at", "update_at").Where("status > 0 and timestamp >= ?", last7daytime).Order(orderSQL).Limit(int(limit)).Offset(int(offset)).Scan(ctx)
+	query := db.GetDB().NewSelect().Model(&res).Column("miner_id", "nonce", "token", "position_manager", "direction", "timestamp", "price", "price_4h", "leverage", "status", "status_reason", "create_at", "update_at").ColumnExpr(priceMarksExpr).Where("timestamp >= ?", last7daytime)
+	if !includeExcluded {
+		query = query.Where("status > 0")
+	}
+
+	err := query.Order(orderSQL).Limit(int(limit)).Offset(int(offset)).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -63,44 +72,32 @@ func getAllTrades(times, pageStr, limitStr string) ([]model.ResTokenTrade, error
 	return res, nil
 }
 
+// parseBoolParam parse an optional boolean query parameter, false when it is empty
+func parseBoolParam(str string) (bool, error) {
+	if str == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(str)
+}
+
 func GetUserTraddes(c *gin.Context) {
 	r := &Response{
 		Code:    http.StatusOK,
 		Message: "success",
 	}
 	defer func(r *Response) {
-		c.JSON(http.StatusOK, r)
+		writeResponse(c, r)
 	}(r)
 
-	userIdStr := c.Query("userId")
-	pubKeyStr := c.Query("pubKey")
-	timeStr := c.Query("timestamp")
-	sigStr := c.Query("sig")
+	userIdStr, pubKeyStr := authCaller(c)
 
-	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "Timestamp": timeStr, "Signature": sigStr}).Info("GetUserTraddes info")
-
-	rawData := fmt.Sprintf("%s%s%s", userIdStr, pubKeyStr, timeStr)
-	err := VerifySign(rawData, pubKeyStr, sigStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetUserTraddes VerifySign failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "verify sig failed"
-		return
-	}
-
-	err = CheckQueryRateLimit(pubKeyStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetUserTraddes CheckQueryRateLimit failed")
-		r.Code = http.StatusTooManyRequests
-		r.Message = "access limit exceeded, please try again later"
-		return
-	}
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr}).Info("GetUserTraddes info")
 
 	result, err := getUserTrades(userIdStr)
 	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetUserTraddes getUserTrades failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "get user trades failed"
+		r.Fail(ErrInternal, "get user trades failed")
 		return
 	}
 
@@ -114,57 +111,29 @@ func GetAllTraddes(c *gin.Context) {
 		Message: "success",
 	}
 	defer func(r *Response) {
-		c.JSON(http.StatusOK, r)
+		writeResponse(c, r)
 	}(r)
 
-	userIdStr := c.Query("userId")
-	pubKeyStr := c.Query("pubKey")
-	timeStr := c.Query("timestamp")
-	sigStr := c.Query("sig")
+	userIdStr, pubKeyStr := authCaller(c)
 	page := c.Query("page")
 	limit := c.Query("limit")
 
 	tradetimeStr := c.Query("tradetime")
+	excludedStr := c.Query("excluded")
 
-	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "Timestamp": timeStr, "Signature": sigStr, "TradeTime": tradetimeStr, "Page": page, "Limit": limit}).Info("GetAllTraddes info")
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr, "TradeTime": tradetimeStr, "Page": page, "Limit": limit, "Excluded": excludedStr}).Info("GetAllTraddes info")
 
-	rawData := fmt.Sprintf("%s%s%s", userIdStr, pubKeyStr, timeStr)
-	err := VerifySign(rawData, pubKeyStr, sigStr)
+	includeExcluded, err := parseBoolParam(excludedStr)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetAllTraddes VerifySign failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "verify sig failed"
-		return
-	}
-
-	err = CheckQueryRateLimit(pubKeyStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetAllTraddes CheckQueryRateLimit failed")
-		r.Code = http.StatusTooManyRequests
-		r.Message = "access limit exceeded, please try again later"
-		return
-	}
-
-	isMiner, err := IsMinerOrValidor(userIdStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetAllTraddes validator not registered")
-		r.Code = http.StatusInternalServerError
-		r.Message = "validator not registered"
-		return
-	}
-
-	if isMiner {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetAllTraddes validator has no access to get all trades")
-		r.Code = http.StatusInternalServerError
-		r.Message = "validator has no access"
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetAllTraddes parse excluded failed")
+		r.Fail(ErrInvalidParams, "invalid excluded")
 		return
 	}
 
-	result, err := getAllTrades(tradetimeStr, page, limit)
+	result, err := getAllTrades(tradetimeStr, page, limit, includeExcluded)
 	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetAllTraddes getAllTrades failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "get all trades failed"
+		r.Fail(ErrInternal, "get all trades failed")
 		return
 	}
 
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/redis"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type QuotaCounter struct {
+	Token     string `json:"token,omitempty"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+	Window    int64  `json:"window"`
+	Reset     int64  `json:"reset"`
+}
+
+type LeverageQuota struct {
+	Token     string `json:"token"`
+	Direction int    `json:"direction"`
+	Count     int64  `json:"count"`
+	Limit     int64  `json:"limit"`
+	Locked    bool   `json:"locked"`
+	// seconds before the counter expires, -1 when it has no expiration
+	TTL int64 `json:"ttl"`
+}
+
+type OutQuota struct {
+	Exempt        bool            `json:"exempt"`
+	TradeMinute   *QuotaCounter   `json:"trade_min_rate_limit"`
+	TradeDay      *QuotaCounter   `json:"trade_day_rate_limit"`
+	TradeTokenDay []QuotaCounter  `json:"trade_token_day_rate_limit"`
+	QueryMinute   *QuotaCounter   `json:"trade_query_min_rate_limit"`
+	Leverage      []LeverageQuota `json:"leverage"`
+}
+
+func peekQuota(key, token string, rule config.RateLimitRule) (*QuotaCounter, error) {
+	window := ruleWindow(rule)
+	res, err := redis.SlidingWindowPeek(key, rule.Limit, window)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuotaCounter{
+		Token:     token,
+		Limit:     res.Limit,
+		Used:      res.Limit - res.Remaining,
+		Remaining: res.Remaining,
+		Window:    int64(window.Seconds()),
+		Reset:     time.UnixMilli(res.Reset).Unix(),
+	}, nil
+}
+
+// the directions a leverage increase counter is kept for, long and short
+var leverageDirections = []int{1, 0, -1}
+
+// getLeverageQuotas read the leverage increase counters of the miner for every token and direction
+func getLeverageQuotas(minerID string) ([]LeverageQuota, error) {
+	items := make([]LeverageQuota, 0)
+	keys := make([]string, 0)
+	for _, token := range TokenList() {
+		for _, direction := range leverageDirections {
+			items = append(items, LeverageQuota{Token: token, Direction: direction})
+			keys = append(keys, leverageCounterKey(&model.AdsTokenTrade{MinerID: minerID, TokenAddress: token, Direction: direction}))
+		}
+	}
+
+	counters, err := redis.GetCounters(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, _ := getRateLimitPolicy().leverageIncrease()
+
+	res := make([]LeverageQuota, 0)
+	for i, item := range items {
+		// no counter for the token and direction
+		if counters[i].TTL == -2 {
+			continue
+		}
+
+		item.Count = counters[i].Value
+		item.Limit = limit
+		item.Locked = item.Count >= limit
+		item.TTL = -1
+		if counters[i].TTL > 0 {
+			item.TTL = int64(counters[i].TTL.Seconds())
+		}
+
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+func getQuota(pubkey, address string) (*OutQuota, error) {
+	p := getRateLimitPolicy()
+	res := &OutQuota{
+		Exempt:        p.isExempt(pubkey, address),
+		TradeTokenDay: make([]QuotaCounter, 0),
+	}
+
+	role := p.role(address)
+	var err error
+
+	res.TradeMinute, err = peekQuota(tradeMinKey(pubkey), "", p.rule(policyTradeMinute, role, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	res.TradeDay, err = peekQuota(tradeDayKey(pubkey), "", p.rule(policyTradeDay, role, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, token := range TokenList() {
+		counter, err := peekQuota(tradeTokenDayKey(pubkey, token), token, p.tokenDayRule(role, token))
+		if err != nil {
+			return nil, err
+		}
+		res.TradeTokenDay = append(res.TradeTokenDay, *counter)
+	}
+
+	res.QueryMinute, err = peekQuota(queryMinKey(pubkey), "", p.rule(policyQueryMinute, role, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Leverage, err = getLeverageQuotas(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func GetQuota(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	userIdStr, pubKeyStr := authCaller(c)
+
+	logger.Logrus.WithFields(logrus.Fields{"MinerID": userIdStr, "PubKey": pubKeyStr}).Info("GetQuota info")
+
+	result, err := getQuota(pubKeyStr, userIdStr)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetQuota getQuota failed")
+		r.Fail(ErrInternal, "get quota failed")
+		return
+	}
+
+	r.Message = "get quota success"
+	r.Data = result
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
-	"context"
 	"fmt"
+	"math"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/Open0xScope/CommuneXService/core/redis"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -23,67 +27,252 @@ var (
 	mutextokenday     sync.Mutex
 )
 
-func chaeckKeyExp(key string, limit int64, exp time.Duration) error {
-	ctx := context.Background()
-	// Increment the count
-	count, err := redis.GetRedisInst().Incr(ctx, key).Result()
+func tradeMinKey(pubkey string) string {
+	return fmt.Sprintf("trade_min_rate_limit:%s", pubkey)
+}
+
+func tradeDayKey(pubkey string) string {
+	return fmt.Sprintf("trade_day_rate_limit:%s", pubkey)
+}
+
+func tradeTokenDayKey(pubkey, tokenAddr string) string {
+	return fmt.Sprintf("trade_token_day_rate_limit:%s:%s", pubkey, tokenAddr)
+}
+
+func queryMinKey(pubkey string) string {
+	return fmt.Sprintf("trade_query_min_rate_limit:%s", pubkey)
+}
+
+// tokenDayLimit is the daily trade limit of the token without role override
+func tokenDayLimit(tokenAddr string) int64 {
+	return getRateLimitPolicy().tokenDayRule("", tokenAddr).Limit
+}
+
+// chaeckKeyExp count the request in the sliding window of the key, the rejected requests are not counted
+func chaeckKeyExp(key string, limit int64, exp time.Duration) (*redis.RateLimitResult, error) {
+	res, err := redis.SlidingWindowAllow(key, limit, exp)
 	if err != nil {
-		return fmt.Errorf("failed to increment key: %v", err)
+		return nil, fmt.Errorf("failed to check key: %v", err)
 	}
 
-	if count == 1 {
-		err = redis.GetRedisInst().Expire(ctx, key, exp).Err()
-		if err != nil {
-			return fmt.Errorf("failed to expire key: %v", err)
-		}
+	if !res.Allowed {
+		return res, fmt.Errorf("current:maximum %v : %v", res.Limit-res.Remaining, res.Limit)
+	}
+	return res, nil
+}
+
+// peekKeyExp check the sliding window of the key without counting the request
+func peekKeyExp(key string, limit int64, exp time.Duration) error {
+	res, err := redis.SlidingWindowPeek(key, limit, exp)
+	if err != nil {
+		return fmt.Errorf("failed to get key: %v", err)
 	}
 
-	if count > int64(limit) {
-		return fmt.Errorf("current:maximum %v : %v", count, limit)
+	if !res.Allowed {
+		return fmt.Errorf("current:maximum %v : %v", res.Limit-res.Remaining, res.Limit)
 	}
 	return nil
 }
 
-func CheckTradeRateLimit(pubkey string) error {
-	redisKey := fmt.Sprintf("trade_min_rate_limit:%s", pubkey)
+func CheckTradeRateLimit(pubkey, address, endpoint string) (*redis.RateLimitResult, error) {
+	p := getRateLimitPolicy()
+	if p.isExempt(pubkey, address) {
+		return nil, nil
+	}
 
-	err := chaeckKeyExp(redisKey, 20, time.Minute)
+	key, rule := p.minuteCounter(policyTradeMinute, tradeMinKey(pubkey), p.role(address), endpoint)
+	res, err := chaeckKeyExp(key, rule.Limit, ruleWindow(rule))
 	if err != nil {
-		return fmt.Errorf("CheckTradeRateLimit,%s %v", pubkey, err)
+		return res, fmt.Errorf("CheckTradeRateLimit,%s %v", pubkey, err)
 	}
 
-	return nil
+	return res, nil
 }
 
-func CheckTradeRateLimitDay(pubkey string) error {
-	redisKey := fmt.Sprintf("trade_day_rate_limit:%s", pubkey)
+func CheckTradeRateLimitDay(pubkey, address string) (*redis.RateLimitResult, error) {
+	p := getRateLimitPolicy()
+	if p.isExempt(pubkey, address) {
+		return nil, nil
+	}
 
-	err := chaeckKeyExp(redisKey, 100, 24*time.Hour)
+	rule := p.rule(policyTradeDay, p.role(address), "")
+	res, err := chaeckKeyExp(tradeDayKey(pubkey), rule.Limit, ruleWindow(rule))
 	if err != nil {
-		return fmt.Errorf("CheckTradeRateLimitDay,%s %v", pubkey, err)
+		return res, fmt.Errorf("CheckTradeRateLimitDay,%s %v", pubkey, err)
 	}
 
-	return nil
+	return res, nil
 }
 
-func CheckTradeTokenRateLimitDay(pubkey, tokenAddr string) error {
-	redisKey := fmt.Sprintf("trade_token_day_rate_limit:%s:%s", pubkey, tokenAddr)
+func CheckTradeTokenRateLimitDay(pubkey, address, tokenAddr string) (*redis.RateLimitResult, error) {
+	p := getRateLimitPolicy()
+	if p.isExempt(pubkey, address) {
+		return nil, nil
+	}
 
-	err := chaeckKeyExp(redisKey, 50, 24*time.Hour)
+	rule := p.tokenDayRule(p.role(address), tokenAddr)
+	res, err := chaeckKeyExp(tradeTokenDayKey(pubkey, tokenAddr), rule.Limit, ruleWindow(rule))
 	if err != nil {
-		return fmt.Errorf("CheckTradeTokenRateLimitDay,%s:%s %v", pubkey, tokenAddr, err)
+		return res, fmt.Errorf("CheckTradeTokenRateLimitDay,%s:%s %v", pubkey, tokenAddr, err)
 	}
 
-	return nil
+	return res, nil
 }
 
-func CheckQueryRateLimit(pubkey string) error {
-	redisKey := fmt.Sprintf("trade_query_min_rate_limit:%s", pubkey)
+func CheckQueryRateLimit(pubkey, address, endpoint string) (*redis.RateLimitResult, error) {
+	p := getRateLimitPolicy()
+	if p.isExempt(pubkey, address) {
+		return nil, nil
+	}
 
-	err := chaeckKeyExp(redisKey, 60, time.Minute)
+	key, rule := p.minuteCounter(policyQueryMinute, queryMinKey(pubkey), p.role(address), endpoint)
+	res, err := chaeckKeyExp(key, rule.Limit, ruleWindow(rule))
 	if err != nil {
-		return fmt.Errorf("CheckQueryRateLimit,%s %v", pubkey, err)
+		return res, fmt.Errorf("CheckQueryRateLimit,%s %v", pubkey, err)
 	}
 
-	return nil
+	return res, nil
+}
+
+// CheckClientRateLimit charge the query rate limit of an unauthenticated client by its ip with the public role rule
+func CheckClientRateLimit(clientIP, endpoint string) (*redis.RateLimitResult, error) {
+	p := getRateLimitPolicy()
+
+	key, rule := p.minuteCounter(policyQueryMinute, queryMinKey("ip:"+clientIP), RolePublic, endpoint)
+	res, err := chaeckKeyExp(key, rule.Limit, ruleWindow(rule))
+	if err != nil {
+		return res, fmt.Errorf("CheckClientRateLimit,%s %v", clientIP, err)
+	}
+
+	return res, nil
+}
+
+// PeekTradeRateLimits check the trade rate limits of the endpoint without charging them,
+// it returns the message of every exceeded limit
+func PeekTradeRateLimits(pubkey, address, tokenAddr, endpoint string) []string {
+	res := make([]string, 0)
+
+	p := getRateLimitPolicy()
+	if p.isExempt(pubkey, address) {
+		return res
+	}
+	role := p.role(address)
+
+	minKey, minRule := p.minuteCounter(policyTradeMinute, tradeMinKey(pubkey), role, endpoint)
+	if err := peekKeyExp(minKey, minRule.Limit, ruleWindow(minRule)); err != nil {
+		res = append(res, "access limit exceeded, please try again later")
+	}
+
+	dayRule := p.rule(policyTradeDay, role, "")
+	if err := peekKeyExp(tradeDayKey(pubkey), dayRule.Limit, ruleWindow(dayRule)); err != nil {
+		res = append(res, "user access day limit exceeded, please try again later")
+	}
+
+	tokenRule := p.tokenDayRule(role, tokenAddr)
+	if err := peekKeyExp(tradeTokenDayKey(pubkey, tokenAddr), tokenRule.Limit, ruleWindow(tokenRule)); err != nil {
+		res = append(res, "token access day limit exceeded, please try again later")
+	}
+
+	return res
+}
+
+// tradeRateLimiter charge the trade rate limits of one request and keep the most restrictive result for the headers
+type tradeRateLimiter struct {
+	// a batch charges the per-minute limit once per pubkey
+	minuteCharged map[string]error
+	result        *redis.RateLimitResult
+	// the entries counted for the trade being checked, the per-minute one of a batch is counted for the request
+	charged []*redis.RateLimitResult
+	// the route of the request, its own rule overrides the per-minute policy
+	endpoint string
+}
+
+func newTradeRateLimiter(batch bool, endpoint string) *tradeRateLimiter {
+	l := &tradeRateLimiter{endpoint: endpoint}
+	if batch {
+		l.minuteCharged = make(map[string]error)
+	}
+	return l
+}
+
+func (l *tradeRateLimiter) keep(res *redis.RateLimitResult) {
+	if res == nil {
+		return
+	}
+
+	if l.result == nil || (l.result.Allowed && !res.Allowed) || (l.result.Allowed == res.Allowed && res.Remaining < l.result.Remaining) {
+		l.result = res
+	}
+}
+
+// begin start the checks of a new trade
+func (l *tradeRateLimiter) begin() {
+	l.charged = nil
+}
+
+func (l *tradeRateLimiter) charge(res *redis.RateLimitResult) {
+	if res != nil && res.Member != "" {
+		l.charged = append(l.charged, res)
+	}
+}
+
+// refund remove the entries counted for the trade, it is called when the trade is not recorded
+func (l *tradeRateLimiter) refund() {
+	for _, res := range l.charged {
+		err := redis.SlidingWindowRefund(res.Key, res.Member)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Key": res.Key}).Warn("tradeRateLimiter refund failed")
+		}
+	}
+	l.charged = nil
+}
+
+func (l *tradeRateLimiter) minute(pubkey, address string) error {
+	if l.minuteCharged != nil {
+		if err, ok := l.minuteCharged[pubkey]; ok {
+			return err
+		}
+	}
+
+	res, err := CheckTradeRateLimit(pubkey, address, l.endpoint)
+	l.keep(res)
+	if l.minuteCharged != nil {
+		l.minuteCharged[pubkey] = err
+	} else {
+		l.charge(res)
+	}
+	return err
+}
+
+func (l *tradeRateLimiter) day(pubkey, address string) error {
+	res, err := CheckTradeRateLimitDay(pubkey, address)
+	l.keep(res)
+	l.charge(res)
+	return err
+}
+
+func (l *tradeRateLimiter) tokenDay(pubkey, address, tokenAddr string) error {
+	res, err := CheckTradeTokenRateLimitDay(pubkey, address, tokenAddr)
+	l.keep(res)
+	l.charge(res)
+	return err
+}
+
+// setRateLimitHeaders write the state of the rate limit, Retry-After is only set on rejection
+func setRateLimitHeaders(c *gin.Context, res *redis.RateLimitResult) {
+	if res == nil {
+		return
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(time.UnixMilli(res.Reset).Unix(), 10))
+
+	if !res.Allowed {
+		retry := int64(math.Ceil(res.RetryAfter().Seconds()))
+		if retry < 1 {
+			retry = 1
+		}
+		c.Header("Retry-After", strconv.FormatInt(retry, 10))
+	}
 }
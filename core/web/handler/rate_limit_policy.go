@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	policyTradeMinute   = "trade_minute"
+	policyTradeDay      = "trade_day"
+	policyTradeTokenDay = "trade_token_day"
+	policyQueryMinute   = "query_minute"
+
+	defaultLeverageIncreaseLimit   = 7
+	defaultLeverageIncreasePenalty = 7 * 24 * 3600
+)
+
+var defaultRateLimits = map[string]config.RateLimitRule{
+	policyTradeMinute:   {Limit: 20, Window: 60},
+	policyTradeDay:      {Limit: 100, Window: 24 * 3600},
+	policyTradeTokenDay: {Limit: 50, Window: 24 * 3600},
+	policyQueryMinute:   {Limit: 60, Window: 60},
+}
+
+type rateLimitPolicy struct {
+	conf   config.RateLimitConfig
+	exempt map[string]bool
+}
+
+var (
+	policyMutex sync.RWMutex
+	policy      = &rateLimitPolicy{exempt: make(map[string]bool)}
+)
+
+// InitRateLimitPolicy load the rate limit policy and reload it on every config change
+func InitRateLimitPolicy() {
+	reloadRateLimitPolicy()
+
+	c := make(chan bool, 1)
+	config.RegistConfChange(c)
+
+	go func() {
+		for range c {
+			reloadRateLimitPolicy()
+		}
+	}()
+}
+
+func reloadRateLimitPolicy() {
+	conf := config.GetRateLimitConfig()
+
+	exempt := make(map[string]bool, len(conf.Exempt))
+	for _, v := range conf.Exempt {
+		exempt[v] = true
+	}
+
+	policyMutex.Lock()
+	policy = &rateLimitPolicy{conf: conf, exempt: exempt}
+	policyMutex.Unlock()
+
+	logger.Logrus.WithFields(logrus.Fields{"RateLimit": conf}).Info("reloadRateLimitPolicy success")
+}
+
+func getRateLimitPolicy() *rateLimitPolicy {
+	policyMutex.RLock()
+	defer policyMutex.RUnlock()
+	return policy
+}
+
+func (p *rateLimitPolicy) isExempt(pubkey, address string) bool {
+	return p.exempt[pubkey] || p.exempt[address]
+}
+
+// role only looks the address up when there are role overrides
+func (p *rateLimitPolicy) role(address string) string {
+	if len(p.conf.Roles) == 0 {
+		return ""
+	}
+
+	return resolveRole(address)
+}
+
+func mergeRule(base, override config.RateLimitRule) config.RateLimitRule {
+	if override.Limit > 0 {
+		base.Limit = override.Limit
+	}
+	if override.Window > 0 {
+		base.Window = override.Window
+	}
+	return base
+}
+
+// rule resolve the rule of the policy, role overrides the policy and endpoint overrides both
+func (p *rateLimitPolicy) rule(name, role, endpoint string) config.RateLimitRule {
+	rule := defaultRateLimits[name]
+
+	if v, ok := p.conf.Policies[name]; ok {
+		rule = mergeRule(rule, v)
+	}
+
+	if v, ok := p.conf.Roles[role][name]; ok && role != "" {
+		rule = mergeRule(rule, v)
+	}
+
+	if v, ok := p.conf.Endpoints[endpoint]; ok && endpoint != "" {
+		rule = mergeRule(rule, v)
+	}
+
+	return rule
+}
+
+// minuteCounter return the key the requests of the endpoint are counted in and the rule of the per-minute policy,
+// the endpoints with their own rule are counted apart
+func (p *rateLimitPolicy) minuteCounter(name, key, role, endpoint string) (string, config.RateLimitRule) {
+	if _, ok := p.conf.Endpoints[endpoint]; ok && endpoint != "" {
+		key = fmt.Sprintf("%s:%s", key, endpoint)
+	}
+
+	return key, p.rule(name, role, endpoint)
+}
+
+// tokenDayRule use the daily trade limit of the token unless the role overrides it
+func (p *rateLimitPolicy) tokenDayRule(role, tokenAddr string) config.RateLimitRule {
+	rule := p.rule(policyTradeTokenDay, "", "")
+
+	if token, ok := GetToken(tokenAddr); ok && token.DailyTradeLimit > 0 {
+		rule.Limit = token.DailyTradeLimit
+	}
+
+	if v, ok := p.conf.Roles[role][policyTradeTokenDay]; ok && role != "" {
+		rule = mergeRule(rule, v)
+	}
+
+	return rule
+}
+
+// leverageIncrease return the open trades with a higher leverage allowed in a row and the lock time after it
+func (p *rateLimitPolicy) leverageIncrease() (int64, time.Duration) {
+	limit := int64(defaultLeverageIncreaseLimit)
+	if p.conf.LeverageIncreaseLimit > 0 {
+		limit = p.conf.LeverageIncreaseLimit
+	}
+
+	penalty := int64(defaultLeverageIncreasePenalty)
+	if p.conf.LeverageIncreasePenalty > 0 {
+		penalty = p.conf.LeverageIncreasePenalty
+	}
+
+	return limit, time.Duration(penalty) * time.Second
+}
+
+func ruleWindow(rule config.RateLimitRule) time.Duration {
+	return time.Duration(rule.Window) * time.Second
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTradeEndpointOverride(t *testing.T) {
+	p := &rateLimitPolicy{conf: config.RateLimitConfig{
+		Endpoints: map[string]config.RateLimitRule{"/createtrades": {Limit: 5}},
+	}}
+
+	key, rule := p.minuteCounter(policyTradeMinute, tradeMinKey("pubkey"), "", "/createtrade")
+	require.Equal(t, tradeMinKey("pubkey"), key)
+	require.Equal(t, defaultRateLimits[policyTradeMinute], rule)
+
+	key, rule = p.minuteCounter(policyTradeMinute, tradeMinKey("pubkey"), "", "/createtrades")
+	require.Equal(t, tradeMinKey("pubkey")+":/createtrades", key)
+	require.Equal(t, int64(5), rule.Limit)
+	require.Equal(t, defaultRateLimits[policyTradeMinute].Window, rule.Window)
+}
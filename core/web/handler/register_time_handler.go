@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 
 	"github.com/Open0xScope/CommuneXService/core/db"
@@ -30,55 +29,19 @@ func GetRegisterTime(c *gin.Context) {
 		Message: "success",
 	}
 	defer func(r *Response) {
-		c.JSON(http.StatusOK, r)
+		writeResponse(c, r)
 	}(r)
 
-	userIdStr := c.Query("userId")
-	pubKeyStr := c.Query("pubKey")
-	timeStr := c.Query("timestamp")
-	sigStr := c.Query("sig")
+	userIdStr, pubKeyStr := authCaller(c)
 
 	starttimeStr := c.Query("starttime")
 
-	logger.Logrus.WithFields(logrus.Fields{"Address": userIdStr, "PubKey": pubKeyStr, "Timestamp": timeStr, "Signature": sigStr, "StartTime": starttimeStr}).Info("GetRegisterTime info")
-
-	rawData := fmt.Sprintf("%s%s%s", userIdStr, pubKeyStr, timeStr)
-	err := VerifySign(rawData, pubKeyStr, sigStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetRegisterTime VerifySign failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "verify sig failed"
-		return
-	}
-
-	err = CheckQueryRateLimit(pubKeyStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetRegisterTime CheckQueryRateLimit failed")
-		r.Code = http.StatusTooManyRequests
-		r.Message = "access limit exceeded, please try again later"
-		return
-	}
-
-	isMiner, err := IsMinerOrValidor(userIdStr)
-	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetRegisterTime validator not registered")
-		r.Code = http.StatusInternalServerError
-		r.Message = "validator not registered"
-		return
-	}
-
-	if isMiner {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetRegisterTime validator has no access to get all trades")
-		r.Code = http.StatusInternalServerError
-		r.Message = "validator has no access"
-		return
-	}
+	logger.Logrus.WithFields(logrus.Fields{"Address": userIdStr, "PubKey": pubKeyStr, "StartTime": starttimeStr}).Info("GetRegisterTime info")
 
 	result, err := getAllRegistertimes(starttimeStr)
 	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetRegisterTime getAllRegistertime failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "get all register time  failed"
+		r.Fail(ErrInternal, "get all register time  failed")
 		return
 	}
 
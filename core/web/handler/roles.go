@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"github.com/Open0xScope/CommuneXService/config"
+)
+
+const (
+	RoleMiner     = "miner"
+	RoleValidator = "validator"
+	RoleAdmin     = "admin"
+	// anyone, the caller does not need to be registered
+	RolePublic = "public"
+)
+
+// isAdmin check the address is one of the configured admins, whatever prefix they are configured with
+func isAdmin(address string) bool {
+	for _, v := range config.GetAccessConfig().Admins {
+		admin, err := canonicalAddress(v)
+		if err != nil {
+			continue
+		}
+
+		if admin == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveRole return the role of the address, empty when it is neither an admin nor registered
+func resolveRole(address string) string {
+	if isAdmin(address) {
+		return RoleAdmin
+	}
+
+	isMiner, err := IsMinerOrValidor(address)
+	if err != nil {
+		return ""
+	}
+
+	if isMiner {
+		return RoleMiner
+	}
+
+	return RoleValidator
+}
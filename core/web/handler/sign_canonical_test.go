@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalQueryMessage(t *testing.T) {
+	query := url.Values{}
+	query.Set("userId", "5F")
+	query.Set("timestamp", "1700000000")
+	query.Set("sigver", "2")
+	query.Set("pubKey", "ab")
+	query.Set("page", "2")
+	query.Set("tradetime", "a b&c")
+	query.Set("sig", "ff")
+
+	msg := canonicalQueryMessage("GET", "/getalltrades", query)
+	require.Equal(t, "CommuneX-Signed-Query-v2\nGET\n/getalltrades\npage=2&pubKey=ab&sigver=2&timestamp=1700000000&tradetime=a+b%26c&userId=5F", msg)
+
+	// the signature binds the path and every parameter
+	require.NotEqual(t, msg, canonicalQueryMessage("GET", "/getlatestprice", query))
+
+	query.Set("page", "3")
+	require.NotEqual(t, msg, canonicalQueryMessage("GET", "/getalltrades", query))
+
+	// sig is not part of the message
+	query.Set("page", "2")
+	query.Set("sig", "00")
+	require.Equal(t, msg, canonicalQueryMessage("GET", "/getalltrades", query))
+}
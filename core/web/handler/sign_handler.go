@@ -1,19 +1,53 @@
 package handler
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"net/url"
 
 	"github.com/ChainSafe/gossamer/lib/common"
-	"github.com/ChainSafe/gossamer/lib/crypto/sr25519"
 )
 
+const (
+	signVersion2 = "2"
+
+	// domain separation, a v2 query signature can not be reused as another message
+	signDomainV2 = "CommuneX-Signed-Query-v2"
+)
+
+// canonicalQueryMessage is the v2 message of a signed query, one line for each of the domain,
+// the method, the path and the query parameters without sig encoded sorted by key
+func canonicalQueryMessage(method, path string, query url.Values) string {
+	params := url.Values{}
+	for k, v := range query {
+		if k == "sig" {
+			continue
+		}
+		params[k] = v
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s", signDomainV2, method, path, params.Encode())
+}
+
+// legacyQueryMessage is the message of the queries signed before v2
+func legacyQueryMessage(userId, pubkey, timestamp string) string {
+	return fmt.Sprintf("%s%s%s", userId, pubkey, timestamp)
+}
+
+// VerifySign verify a sr25519 signature
 func VerifySign(msg, pubkey, sigstr string) error {
-	pubbytes, err := common.HexToBytes("0x" + pubkey)
+	return VerifyKeySign(KeyTypeSr25519, msg, pubkey, sigstr)
+}
+
+// VerifyKeySign verify the signature with the verifier of the key type
+func VerifyKeySign(keyType, msg, pubkey, sigstr string) error {
+	v, err := getKeyVerifier(keyType)
 	if err != nil {
 		return err
 	}
 
-	pub, err := sr25519.NewPublicKey(pubbytes)
+	pubbytes, err := common.HexToBytes("0x" + pubkey)
 	if err != nil {
 		return err
 	}
@@ -23,32 +57,37 @@ func VerifySign(msg, pubkey, sigstr string) error {
 		return err
 	}
 
-	ok, err := pub.Verify([]byte(msg), sighex)
+	return v.verify(pubbytes, []byte(msg), sighex)
+}
+
+// CheckAddress check the address of a sr25519 pubkey
+func CheckAddress(pubkey, addr string) error {
+	return CheckKeyAddress(KeyTypeSr25519, pubkey, addr)
+}
+
+// CheckKeyAddress check the address with an accepted prefix is the account of the pubkey of the key type
+func CheckKeyAddress(keyType, pubkey, addr string) error {
+	v, err := getKeyVerifier(keyType)
 	if err != nil {
 		return err
 	}
 
-	if !ok {
-		return errors.New("failed to verify signature")
+	pub, err := common.HexToBytes("0x" + pubkey)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func CheckAddress(pubkey, addr string) error {
-	// randomly generated from subkey
-	pub, err := common.HexToBytes("0x" + pubkey)
+	accountID, err := v.accountID(pub)
 	if err != nil {
 		return err
 	}
 
-	pk, err := sr25519.NewPublicKey(pub)
+	addrID, err := decodeAddress(addr)
 	if err != nil {
 		return err
 	}
 
-	a := pk.Address()
-	if addr != string(a) {
+	if !bytes.Equal(addrID, accountID) {
 		return errors.New("address and pubkey not match")
 	}
 
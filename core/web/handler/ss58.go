@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/core/ss58"
+)
+
+// decodeAddress return the account id of the address encoded with an accepted prefix
+func decodeAddress(addr string) ([]byte, error) {
+	prefix, accountID, err := ss58.Decode(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := config.GetAddressConfig()
+	for _, v := range conf.AcceptedPrefixes {
+		if v == prefix {
+			return accountID, nil
+		}
+	}
+
+	return nil, fmt.Errorf("address prefix %d is not accepted", prefix)
+}
+
+// canonicalAddress re-encode the address with the canonical prefix, it is the form stored and looked up
+func canonicalAddress(addr string) (string, error) {
+	accountID, err := decodeAddress(addr)
+	if err != nil {
+		return "", err
+	}
+
+	return ss58.Encode(config.GetAddressConfig().CanonicalPrefix, accountID), nil
+}
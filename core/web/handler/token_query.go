@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+)
+
+type OutToken struct {
+	Address         string  `json:"address"`
+	Chain           string  `json:"chain"`
+	Symbol          string  `json:"symbol"`
+	Decimals        int     `json:"decimals"`
+	MinLeverage     float64 `json:"min_leverage"`
+	MaxLeverage     float64 `json:"max_leverage"`
+	LeverageStep    float64 `json:"leverage_step"`
+	DailyTradeLimit int64   `json:"daily_trade_limit"`
+	Enabled         bool    `json:"enabled"`
+	LatestPriceTime string  `json:"latest_price_time"`
+}
+
+type tokenPriceTime struct {
+	TokenAddress string `bun:"token_address"`
+	Pt           string `bun:"pt"`
+}
+
+func getLatestPriceTimes(addrs []string) (map[string]string, error) {
+	res := make([]tokenPriceTime, 0)
+	if len(addrs) == 0 {
+		return map[string]string{}, nil
+	}
+
+	err := db.GetDB().NewSelect().
+		Table("crawler_ods.ods_crawler_coingecko_trade_token_price").
+		Column("token_address").
+		ColumnExpr("max(pt) AS pt").
+		Where("chain IN (?)", bun.In(ChainList())).
+		Where("token_address IN (?)", bun.In(addrs)).
+		Group("token_address").
+		Scan(context.Background(), &res)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]string, len(res))
+	for _, v := range res {
+		times[v.TokenAddress] = v.Pt
+	}
+
+	return times, nil
+}
+
+// the latest price times are shared by the callers of /tokens for priceTimesTTL
+const priceTimesTTL = 30 * time.Second
+
+var priceTimesCache struct {
+	sync.Mutex
+	key       string
+	times     map[string]string
+	expiresAt time.Time
+}
+
+// cachedLatestPriceTimes return the latest price times of the tokens, read again once expired or when the tokens change
+func cachedLatestPriceTimes(addrs []string) (map[string]string, error) {
+	key := strings.Join(addrs, ",")
+
+	priceTimesCache.Lock()
+	defer priceTimesCache.Unlock()
+
+	if priceTimesCache.key == key && time.Now().Before(priceTimesCache.expiresAt) {
+		return priceTimesCache.times, nil
+	}
+
+	times, err := getLatestPriceTimes(addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	priceTimesCache.key = key
+	priceTimesCache.times = times
+	priceTimesCache.expiresAt = time.Now().Add(priceTimesTTL)
+
+	return times, nil
+}
+
+func getTokens() ([]OutToken, error) {
+	tokens := AllTokens()
+
+	addrs := make([]string, 0, len(tokens))
+	for _, v := range tokens {
+		addrs = append(addrs, v.Address)
+	}
+
+	times, err := cachedLatestPriceTimes(addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]OutToken, 0, len(tokens))
+	for _, v := range tokens {
+		res = append(res, OutToken{
+			Address:         v.Address,
+			Chain:           v.Chain,
+			Symbol:          v.Symbol,
+			Decimals:        v.Decimals,
+			MinLeverage:     v.MinLeverage,
+			MaxLeverage:     v.MaxLeverage,
+			LeverageStep:    v.LeverageStep,
+			DailyTradeLimit: tokenDayLimit(v.Address),
+			Enabled:         v.Enabled,
+			LatestPriceTime: times[v.Address],
+		})
+	}
+
+	return res, nil
+}
+
+func GetTokens(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	result, err := getTokens()
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("GetTokens getTokens failed")
+		r.Fail(ErrInternal, "get tokens failed")
+		return
+	}
+
+	r.Message = "get tokens success"
+	r.Data = result
+}
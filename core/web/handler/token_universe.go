@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Open0xScope/CommuneXService/config"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// used when the config has no trade chains or tokens
+var defaultChains = []string{
+	"btc",
+	"eth",
+	"op",
+	"arb",
+}
+
+var defaultTokens = []config.TokenConfig{
+	{Address: "0x0000000000000000000000000000000000000000", Chain: "eth", Symbol: "ETH", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 50, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599", Chain: "btc", Symbol: "BTC", Decimals: 8, MinLeverage: 0.1, MaxLeverage: 50, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x514910771af9ca656af840dff83e8264ecf986ca", Chain: "eth", Symbol: "LINK", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", Chain: "eth", Symbol: "UNI", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x6982508145454ce325ddbe47a25d4ec3d2311933", Chain: "eth", Symbol: "PEPE", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0xaea46a60368a7bd060eec7df8cba43b7ef41ad85", Chain: "eth", Symbol: "FET", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x808507121b80c02388fad14726482e061b8da827", Chain: "eth", Symbol: "PENDLE", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x9d65ff81a3c488d585bbfb0bfe3c7707c7917f54", Chain: "eth", Symbol: "SSV", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x6e2a43be0b1d33b726f0ca3b8de60b3482b8b050", Chain: "eth", Symbol: "ARKM", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0xc18360217d8f7ab5e7c516566761ea12ce7f9d72", Chain: "eth", Symbol: "ENS", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0xa9b1eb5908cfc3cdf91f9b8b3a74108598009096", Chain: "eth", Symbol: "AUCTION", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x57e114b691db790c35207b2e685d4a43181e6061", Chain: "eth", Symbol: "ENA", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x4200000000000000000000000000000000000042", Chain: "op", Symbol: "OP", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x912ce59144191c1204e64559fe8253a0e49e6548", Chain: "arb", Symbol: "ARB", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x5a98fcbea516cf06857215779fd812ca3bef1b32", Chain: "eth", Symbol: "LDO", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x7fc66500c84a76ad7e9c93437bfc5ac33e2ddae9", Chain: "eth", Symbol: "AAVE", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0xfaba6f8e4a5e8ab82f62fe7c39859fa577269be3", Chain: "eth", Symbol: "ONDO", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0xc011a73ee8576fb46f5e1c5751ca3b9fe0af2a6f", Chain: "eth", Symbol: "SNX", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x4d224452801aced8b2f0aebe155379bb5d594381", Chain: "eth", Symbol: "APE", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+	{Address: "0x5283d291dbcf85356a21ba090e6db59121208b44", Chain: "eth", Symbol: "BLUR", Decimals: 18, MinLeverage: 0.1, MaxLeverage: 20, LeverageStep: 0.1, Enabled: true, DailyTradeLimit: 50},
+}
+
+var (
+	universeMutex sync.RWMutex
+	tokenIndex    = make(map[string]config.TokenConfig)
+	tokens        []config.TokenConfig
+	enabledTokens []string
+	chains        []string
+)
+
+// InitTokenUniverse load the tradable tokens and reload them on every config change
+func InitTokenUniverse() {
+	reloadTokenUniverse()
+
+	c := make(chan bool, 1)
+	config.RegistConfChange(c)
+
+	go func() {
+		for range c {
+			reloadTokenUniverse()
+		}
+	}()
+}
+
+func reloadTokenUniverse() {
+	conf := config.GetTradeConfig()
+
+	confTokens := conf.Tokens
+	if len(confTokens) == 0 {
+		confTokens = defaultTokens
+	}
+
+	confChains := conf.Chains
+	if len(confChains) == 0 {
+		confChains = defaultChains
+	}
+
+	index := make(map[string]config.TokenConfig, len(confTokens))
+	all := make([]config.TokenConfig, 0, len(confTokens))
+	enabled := make([]string, 0, len(confTokens))
+	for _, v := range confTokens {
+		v.Address = strings.ToLower(v.Address)
+		index[v.Address] = v
+		all = append(all, v)
+		if v.Enabled {
+			enabled = append(enabled, v.Address)
+		}
+	}
+
+	universeMutex.Lock()
+	tokenIndex = index
+	tokens = all
+	enabledTokens = enabled
+	chains = confChains
+	universeMutex.Unlock()
+
+	logger.Logrus.WithFields(logrus.Fields{"Tokens": len(all), "Enabled": len(enabled), "Chains": confChains}).Info("reloadTokenUniverse success")
+}
+
+// TokenList return the addresses of the enabled tokens
+func TokenList() []string {
+	universeMutex.RLock()
+	defer universeMutex.RUnlock()
+	return enabledTokens
+}
+
+// ChainList return the chains of the price data
+func ChainList() []string {
+	universeMutex.RLock()
+	defer universeMutex.RUnlock()
+	return chains
+}
+
+// AllTokens return every configured token, including the disabled ones
+func AllTokens() []config.TokenConfig {
+	universeMutex.RLock()
+	defer universeMutex.RUnlock()
+	return tokens
+}
+
+func GetToken(addr string) (config.TokenConfig, bool) {
+	universeMutex.RLock()
+	defer universeMutex.RUnlock()
+	token, ok := tokenIndex[strings.ToLower(addr)]
+	return token, ok
+}
@@ -10,12 +10,17 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/Open0xScope/CommuneXService/config"
 	"github.com/Open0xScope/CommuneXService/core/db"
 	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/position"
+	"github.com/Open0xScope/CommuneXService/core/pricejob"
 	"github.com/Open0xScope/CommuneXService/core/redis"
+	"github.com/Open0xScope/CommuneXService/core/whitelist"
 	"github.com/Open0xScope/CommuneXService/utils/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
 )
 
 type InCreateTrade struct {
@@ -28,6 +33,39 @@ type InCreateTrade struct {
 	Timestamp       int64   `json:"timestamp"`
 	Leverage        float64 `json:"leverage"`
 	Signature       string  `json:"signature"`
+	KeyType         string  `json:"key_type"`
+}
+
+const (
+	tradeAccepted        = "accepted"
+	tradeAlreadyAccepted = "already_accepted"
+
+	// signature of the close trades inserted by the service itself
+	autoCloseSignature = "no need sign"
+)
+
+type OutCreateTrade struct {
+	Status string              `json:"status"`
+	Trade  model.ResTokenTrade `json:"trade"`
+}
+
+func newOutCreateTrade(status string, trade *model.AdsTokenTrade) *OutCreateTrade {
+	return &OutCreateTrade{
+		Status: status,
+		Trade: model.ResTokenTrade{
+			MinerID:         trade.MinerID,
+			Nonce:           trade.Nonce,
+			TokenAddress:    trade.TokenAddress,
+			PositionManager: trade.PositionManager,
+			Direction:       trade.Direction,
+			Timestamp:       trade.Timestamp,
+			TradePrice:      trade.TradePrice,
+			TradePrice4H:    trade.TradePrice4H,
+			Leverage:        trade.Leverage,
+			CreatedAt:       trade.CreatedAt,
+			UpdatedAt:       trade.UpdatedAt,
+		},
+	}
 }
 
 func isDivisible(a, b float64) bool {
@@ -38,9 +76,8 @@ func isDivisible(a, b float64) bool {
 	return math.Abs(quotient-math.Round(quotient)) < 1e-9
 }
 
-func insertTrade(txs *model.AdsTokenTrade) error {
-	ctx := context.Background()
-	sqlRes, err := db.GetDB().NewInsert().Model(txs).Exec(ctx)
+func insertTrade(ctx context.Context, idb bun.IDB, txs *model.AdsTokenTrade) error {
+	sqlRes, err := idb.NewInsert().Model(txs).Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -54,13 +91,51 @@ func insertTrade(txs *model.AdsTokenTrade) error {
 		return errors.New("insert empty item")
 	}
 
-	return nil
+	err = pricejob.Schedule(ctx, idb, txs)
+	if err != nil {
+		return err
+	}
+
+	return position.Apply(ctx, idb, txs)
+}
+
+// getAcceptedTrade return the trade already recorded for the same submission, nil if not found
+func getAcceptedTrade(ctx context.Context, idb bun.IDB, userId string, nonce int64, signature string) (*model.AdsTokenTrade, error) {
+	if signature == "" || signature == autoCloseSignature {
+		return nil, nil
+	}
+
+	var res model.AdsTokenTrade
+	err := idb.NewSelect().Model(&res).Where("miner_id = ? and nonce = ? and signature = ?", userId, nonce, signature).Limit(1).Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// lockTrades serialize the writers of the same miner and token until the transaction ends
+func lockTrades(ctx context.Context, tx bun.Tx, userId, tokenAddr string) error {
+	return position.Lock(ctx, tx, userId, tokenAddr)
+}
+
+func getLatestTrade(ctx context.Context, idb bun.IDB, userId, tokenAddr string) (*model.AdsTokenTrade, error) {
+	return latestTrade(ctx, idb, userId, tokenAddr, true)
 }
 
-func getLatestTrade(userId, tokenAddr string) (*model.AdsTokenTrade, error) {
-	ctx := context.Background()
+// latestTrade return the latest trade of the miner and token, locked for update when forUpdate, nil if not found
+func latestTrade(ctx context.Context, idb bun.IDB, userId, tokenAddr string, forUpdate bool) (*model.AdsTokenTrade, error) {
 	var res model.AdsTokenTrade
-	err := db.GetDB().NewSelect().Model(&res).Where("miner_id = ? and token = ?", userId, tokenAddr).Order("timestamp DESC").Limit(1).Scan(ctx)
+	query := idb.NewSelect().Model(&res).Where("miner_id = ? and token = ?", userId, tokenAddr).Order("timestamp DESC").Limit(1)
+	if forUpdate {
+		query = query.For("UPDATE")
+	}
+
+	err := query.Scan(ctx)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -74,10 +149,8 @@ func getLatestTrade(userId, tokenAddr string) (*model.AdsTokenTrade, error) {
 
 func IsMinerOrValidor(minerid string) (bool, error) {
 	//true is miner,and false is validator when error is not null
-	ctx := context.Background()
-	var res model.AdsMinerWhitelist
-	err := db.GetDB().NewSelect().Model(&res).Where("address = ?", minerid).Scan(ctx)
-	if err == sql.ErrNoRows {
+	res, err := whitelist.Get(minerid)
+	if whitelist.IsNotFound(err) {
 		return false, errors.New("miner is not in whitelist")
 	}
 
@@ -85,67 +158,104 @@ func IsMinerOrValidor(minerid string) (bool, error) {
 		return false, err
 	}
 
-	if res.Stake > 1000000000000 {
-		return false, nil
-	}
+	return classifyEntry(res, config.GetAccessConfig())
+}
 
-	if res.Status < 1 {
+// classifyEntry tell a miner from a validator by the stake, a disabled or deregistered entry is neither
+func classifyEntry(entry *model.AdsMinerWhitelist, conf config.AccessConfig) (bool, error) {
+	if entry.Status < conf.MinerStatus {
 		return false, errors.New("miner is not invalided")
 	}
 
+	if entry.Stake > conf.ValidatorStake {
+		return false, nil
+	}
+
 	return true, nil
 }
 
-func checknewtrade(newTrade *model.AdsTokenTrade) (string, error) {
+type tradeRule struct {
+	name  string
+	check func(newTrade *model.AdsTokenTrade) (string, string, error)
+}
+
+// rules checked before the rate limits are charged
+var tradeSignRules = []tradeRule{
+	{"whitelist", checkTradeWhitelist},
+	{"address", checkTradeAddress},
+	{"signature", checkTradeSignature},
+}
+
+// rules checked after the rate limits, leverage must be checked before the others since it fills the default leverage
+var tradeParamRules = []tradeRule{
+	{"token", checkTradeToken},
+	{"leverage", checkTradeLeverage},
+	{"position_manager", checkTradePositionManager},
+	{"timestamp", checkTradeTimestamp},
+}
+
+// signedMinerID is the miner id as submitted, the signature covers it instead of the canonical one
+func signedMinerID(trade *model.AdsTokenTrade) string {
+	if trade.SignedMinerID != "" {
+		return trade.SignedMinerID
+	}
+
+	return trade.MinerID
+}
+
+func checkTradeWhitelist(newTrade *model.AdsTokenTrade) (string, string, error) {
 	_, err := IsMinerOrValidor(newTrade.MinerID)
 	if err != nil {
-		return "miner not registered", err
+		return ErrNotRegistered, "miner not registered", err
 	}
 
-	err = CheckAddress(newTrade.PubKey, newTrade.MinerID)
+	return "", "", nil
+}
+
+func checkTradeAddress(newTrade *model.AdsTokenTrade) (string, string, error) {
+	err := CheckKeyAddress(newTrade.KeyType, newTrade.PubKey, signedMinerID(newTrade))
 	if err != nil {
-		return "address and key not match", err
+		return ErrAddressMismatch, "address and key not match", err
 	}
 
-	msg := fmt.Sprintf("%s%s%d%s%s%d%d", newTrade.MinerID, newTrade.PubKey, newTrade.Nonce, newTrade.TokenAddress, newTrade.PositionManager, newTrade.Direction, newTrade.Timestamp)
+	return "", "", nil
+}
+
+func checkTradeSignature(newTrade *model.AdsTokenTrade) (string, string, error) {
+	msg := fmt.Sprintf("%s%s%d%s%s%d%d", signedMinerID(newTrade), newTrade.PubKey, newTrade.Nonce, newTrade.TokenAddress, newTrade.PositionManager, newTrade.Direction, newTrade.Timestamp)
 	if newTrade.Leverage != 0 {
 		msg += fmt.Sprintf("%v", newTrade.Leverage)
 	}
 
-	err = VerifySign(msg, newTrade.PubKey, newTrade.Signature)
+	err := VerifyKeySign(newTrade.KeyType, msg, newTrade.PubKey, newTrade.Signature)
 	if err != nil {
-		return "sign error", err
+		return ErrSignatureInvalid, "sign error", err
 	}
 
-	err = CheckTradeRateLimit(newTrade.PubKey)
-	if err != nil {
-		return "access limit exceeded, please try again later", err
-	}
+	return "", "", nil
+}
 
-	err = CheckTradeRateLimitDay(newTrade.PubKey)
-	if err != nil {
-		return "user access day limit exceeded, please try again later", err
+func checkTradeToken(newTrade *model.AdsTokenTrade) (string, string, error) {
+	token, ok := GetToken(newTrade.TokenAddress)
+	if !ok || !token.Enabled {
+		return ErrTokenNotTradable, "token is not tradable", fmt.Errorf("token %s is not tradable", newTrade.TokenAddress)
 	}
 
-	err = CheckTradeTokenRateLimitDay(newTrade.PubKey, newTrade.TokenAddress)
-	if err != nil {
-		return "token access day limit exceeded, please try again later", err
-	}
+	return "", "", nil
+}
 
+func checkTradeLeverage(newTrade *model.AdsTokenTrade) (string, string, error) {
 	leverage := newTrade.Leverage
 	if leverage != 0 {
-		if newTrade.TokenAddress == "0x0000000000000000000000000000000000000000" || newTrade.TokenAddress == "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599" {
-			if leverage < 0.1 || leverage > 50 {
-				return "the leverage is not in the range for eth/btc", errors.New("the leverage is not in the range")
-			}
-		} else {
-			if leverage < 0.1 || leverage > 20 {
-				return "the leverage is not in the range for others", errors.New("the leverage is not in the range")
+		// the token rule reports the unknown tokens
+		if token, ok := GetToken(newTrade.TokenAddress); ok {
+			if leverage < token.MinLeverage || leverage > token.MaxLeverage {
+				return ErrLeverageOutOfRange, fmt.Sprintf("the leverage is not in the range [%v, %v] for %s", token.MinLeverage, token.MaxLeverage, token.Symbol), errors.New("the leverage is not in the range")
 			}
-		}
 
-		if !isDivisible(leverage, 0.1) {
-			return "the leverage is not an integer multiple of the basic unit", errors.New("the leverage is not an integer multiple of the basic unit")
+			if token.LeverageStep > 0 && !isDivisible(leverage, token.LeverageStep) {
+				return ErrLeverageInvalidStep, "the leverage is not an integer multiple of the basic unit", errors.New("the leverage is not an integer multiple of the basic unit")
+			}
 		}
 	} else {
 		leverage = float64(1.0)
@@ -153,75 +263,135 @@ func checknewtrade(newTrade *model.AdsTokenTrade) (string, error) {
 
 	newTrade.Leverage = leverage
 
+	return "", "", nil
+}
+
+func checkTradePositionManager(newTrade *model.AdsTokenTrade) (string, string, error) {
 	if newTrade.PositionManager != "open" && newTrade.PositionManager != "close" {
-		return "position manager invalid", errors.New("position manager is invalid")
+		return ErrPositionManagerInvalid, "position manager invalid", errors.New("position manager is invalid")
 	}
 
+	return "", "", nil
+}
+
+func checkTradeTimestamp(newTrade *model.AdsTokenTrade) (string, string, error) {
 	if newTrade.Timestamp > time.Now().Unix() {
-		return "creation timestamp more than now", errors.New("creation timestamp more than now")
+		return ErrTimestampInvalid, "creation timestamp more than now", errors.New("creation timestamp more than now")
 	}
 
 	last10min := time.Now().Add(-time.Minute).Unix()
 	if newTrade.Timestamp < last10min {
-		return "creation timestamp is old", errors.New("creation timestamp is old")
+		return ErrTimestampInvalid, "creation timestamp is old", errors.New("creation timestamp is old")
 	}
 
-	return "check new trade success", nil
+	return "", "", nil
 }
 
-func checktwotrade(latestTrade, newTrade *model.AdsTokenTrade) (string, error) {
+// checknewtrade check the trade itself, the day quotas are only charged for trades passing the param rules
+func checknewtrade(newTrade *model.AdsTokenTrade, limiter *tradeRateLimiter) (string, string, error) {
+	limiter.begin()
+
+	for _, rule := range tradeSignRules {
+		errCode, msg, err := rule.check(newTrade)
+		if err != nil {
+			return errCode, msg, err
+		}
+	}
+
+	err := limiter.minute(newTrade.PubKey, newTrade.MinerID)
+	if err != nil {
+		return ErrRateLimited, "access limit exceeded, please try again later", err
+	}
+
+	for _, rule := range tradeParamRules {
+		errCode, msg, err := rule.check(newTrade)
+		if err != nil {
+			return errCode, msg, err
+		}
+	}
+
+	err = limiter.day(newTrade.PubKey, newTrade.MinerID)
+	if err != nil {
+		return ErrRateLimited, "user access day limit exceeded, please try again later", err
+	}
+
+	err = limiter.tokenDay(newTrade.PubKey, newTrade.MinerID, newTrade.TokenAddress)
+	if err != nil {
+		return ErrRateLimited, "token access day limit exceeded, please try again later", err
+	}
+
+	return "", "check new trade success", nil
+}
+
+func checktwotrade(latestTrade, newTrade *model.AdsTokenTrade) (string, string, error) {
 	if latestTrade != nil {
 		if newTrade.Nonce == latestTrade.Nonce {
-			return "invalid nonce", errors.New("trade has invalid nonce")
+			return ErrNonceInvalid, "invalid nonce", errors.New("trade has invalid nonce")
 		}
 
 		if newTrade.Timestamp <= latestTrade.Timestamp {
-			return "creation timestamp of latest trade error", errors.New("the timestamp of trade is invalid")
+			return ErrTimestampInvalid, "creation timestamp of latest trade error", errors.New("the timestamp of trade is invalid")
 		}
 
 		if newTrade.PositionManager == "close" && latestTrade.PositionManager != "open" {
-			return "close position manager error", errors.New("close trade is invalid")
+			return ErrPositionConflict, "close position manager error", errors.New("close trade is invalid")
 		}
 
 		if newTrade.PositionManager == "open" && latestTrade.PositionManager != "close" {
-			return "open position manager error", errors.New("open trade is invalid")
+			return ErrPositionConflict, "open position manager error", errors.New("open trade is invalid")
 		}
 	}
 
-	return "check two trade success", nil
+	return "", "check two trade success", nil
+}
+
+// leverageCounter is the leverage increase counter change, it is applied after the trade is committed,
+// the increment is atomic so it needs no lock
+type leverageCounter struct {
+	key   string
+	reset bool
+}
+
+func (l *leverageCounter) apply() error {
+	if l.reset {
+		return redis.DelCounter(l.key)
+	}
+
+	_, err := redis.IncrCounter(l.key)
+	return err
+}
+
+func leverageCounterKey(trade *model.AdsTokenTrade) string {
+	return fmt.Sprintf("%s%s%d", trade.MinerID, trade.TokenAddress, trade.Direction)
 }
 
-func checkTradeLeverageLimit(oldTrade, newTrade *model.AdsTokenTrade) (string, error) {
-	rkey := fmt.Sprintf("%s%s%d", newTrade.MinerID, newTrade.TokenAddress, newTrade.Direction)
+func checkTradeLeverageLimit(ctx context.Context, idb bun.IDB, oldTrade, newTrade *model.AdsTokenTrade) (*leverageCounter, string, string, error) {
+	rkey := leverageCounterKey(newTrade)
+	limit, penalty := getRateLimitPolicy().leverageIncrease()
 
 	cv, err := redis.GetCounterValue(rkey)
 	if err != nil {
-		return "get key value failed", err
+		return nil, ErrInternal, "get key value failed", err
 	}
-	if cv >= 7 {
-		err = redis.SetCounterExpir(rkey, 7*24*time.Hour)
+	if cv >= limit {
+		err = redis.SetCounterExpir(rkey, penalty)
 		if err != nil {
-			return "open trade limit exceeded and set time failed", err
+			return nil, ErrInternal, "open trade limit exceeded and set time failed", err
 		}
-		return "open trade limit exceeded", errors.New("open trade limit exceeded")
+		return nil, ErrLeverageIncreaseLocked, "open trade limit exceeded", errors.New("open trade limit exceeded")
 	}
 
-	if oldTrade.Leverage >= newTrade.Leverage {
-		err = redis.DelCounter(rkey)
-		if err != nil {
-			return "del key failed", err
-		}
-	} else {
-		err = redis.SetCounter(rkey, cv+1)
-		if err != nil {
-			return "set key value failed", err
-		}
+	counter := &leverageCounter{key: rkey, reset: oldTrade.Leverage >= newTrade.Leverage}
+
+	msg, err := insertCloseTrade(ctx, idb, oldTrade)
+	if err != nil {
+		return nil, ErrInternal, msg, err
 	}
 
-	return insertCloseTrade(oldTrade)
+	return counter, "", msg, nil
 }
 
-func insertCloseTrade(trade *model.AdsTokenTrade) (string, error) {
+func insertCloseTrade(ctx context.Context, idb bun.IDB, trade *model.AdsTokenTrade) (string, error) {
 	//insert close trade
 	closeTrade := &model.AdsTokenTrade{
 		MinerID:         trade.MinerID,
@@ -232,14 +402,14 @@ func insertCloseTrade(trade *model.AdsTokenTrade) (string, error) {
 		Direction:       trade.Direction,
 		Timestamp:       trade.Timestamp + 1,
 		TradePrice:      trade.TradePrice,
-		Signature:       "no need sign",
+		Signature:       autoCloseSignature,
 		Status:          1,
 		Leverage:        trade.Leverage,
 		CreatedAt:       time.Now().UTC(),
 		UpdatedAt:       time.Now().UTC(),
 	}
 
-	err := insertTrade(closeTrade)
+	err := insertTrade(ctx, idb, closeTrade)
 	if err != nil {
 		return "insert close trade failed", err
 	}
@@ -247,20 +417,18 @@ func insertCloseTrade(trade *model.AdsTokenTrade) (string, error) {
 	return "insert close trade success", nil
 }
 
-func checkTrade(oldtrade, newTrade *model.AdsTokenTrade) (string, error) {
-	msg, err := checknewtrade(newTrade)
-	if err != nil {
-		return msg, err
-	}
-
+// checkTrade check the new trade against the latest one, an open after open
+// closes the old position and returns the leverage counter change
+func checkTrade(ctx context.Context, idb bun.IDB, oldtrade, newTrade *model.AdsTokenTrade) (*leverageCounter, string, string, error) {
 	if newTrade.PositionManager == "open" && oldtrade != nil && newTrade.PositionManager == oldtrade.PositionManager {
-		return checkTradeLeverageLimit(oldtrade, newTrade)
+		return checkTradeLeverageLimit(ctx, idb, oldtrade, newTrade)
 	}
 
-	return checktwotrade(oldtrade, newTrade)
+	errCode, msg, err := checktwotrade(oldtrade, newTrade)
+	return nil, errCode, msg, err
 }
 
-func updatePrice4H(latestTrade, newTrade *model.AdsTokenTrade) error {
+func updatePrice4H(ctx context.Context, idb bun.IDB, latestTrade, newTrade *model.AdsTokenTrade) error {
 	if latestTrade == nil {
 		return nil
 	}
@@ -269,7 +437,7 @@ func updatePrice4H(latestTrade, newTrade *model.AdsTokenTrade) error {
 		return nil
 	}
 
-	inval := newTrade.Timestamp - latestTrade.Timestamp - 14400
+	inval := newTrade.Timestamp - latestTrade.Timestamp - pricejob.Horizon4H
 	if inval > 0 {
 		return nil
 	}
@@ -277,7 +445,7 @@ func updatePrice4H(latestTrade, newTrade *model.AdsTokenTrade) error {
 	//update trade 4h price
 	latestTrade.TradePrice4H = newTrade.TradePrice
 
-	_, err := db.GetDB().NewUpdate().Model(latestTrade).Set("price_4h = ?", newTrade.TradePrice).Where("miner_id = ? and token = ? and nonce = ?", latestTrade.MinerID, latestTrade.TokenAddress, latestTrade.Nonce).Exec(context.Background())
+	_, err := idb.NewUpdate().Model(latestTrade).Set("price_4h = ?", newTrade.TradePrice).Where("miner_id = ? and token = ? and nonce = ?", latestTrade.MinerID, latestTrade.TokenAddress, latestTrade.Nonce).Exec(ctx)
 	if err != nil {
 		return fmt.Errorf("update trade 4h price,%v", err)
 	}
@@ -285,47 +453,110 @@ func updatePrice4H(latestTrade, newTrade *model.AdsTokenTrade) error {
 	return nil
 }
 
+// createTrade check and record the new trade in one transaction,
+// the redis counters are only touched after the commit.
+// When the same submission was accepted before, newTrade is replaced by the stored one and duplicate is true.
+func createTrade(newTrade *model.AdsTokenTrade) (bool, string, string, error) {
+	var counter *leverageCounter
+	duplicate := false
+	errCode := ErrInternal
+	errmsg := "record trade failed"
+
+	err := db.GetDB().RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		err := lockTrades(ctx, tx, newTrade.MinerID, newTrade.TokenAddress)
+		if err != nil {
+			errmsg = "lock trades failed"
+			return err
+		}
+
+		accepted, err := getAcceptedTrade(ctx, tx, newTrade.MinerID, newTrade.Nonce, newTrade.Signature)
+		if err != nil {
+			errmsg = "get accepted trade failed"
+			return err
+		}
+
+		if accepted != nil {
+			*newTrade = *accepted
+			duplicate = true
+			return nil
+		}
+
+		oldTrade, err := getLatestTrade(ctx, tx, newTrade.MinerID, newTrade.TokenAddress)
+		if err != nil {
+			errmsg = "get latest trade failed"
+			return err
+		}
+
+		counter, errCode, errmsg, err = checkTrade(ctx, tx, oldTrade, newTrade)
+		if err != nil {
+			return err
+		}
+
+		err = insertTrade(ctx, tx, newTrade)
+		if err != nil {
+			errCode = ErrInternal
+			errmsg = "record trade failed"
+			return err
+		}
+
+		err = updatePrice4H(ctx, tx, oldTrade, newTrade)
+		if err != nil {
+			errCode = ErrInternal
+			errmsg = "update 4h price failed"
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, errCode, errmsg, err
+	}
+
+	if counter != nil {
+		err = counter.apply()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Key": counter.key}).Warn("createTrade apply leverage counter failed")
+		}
+	}
+
+	if duplicate {
+		return true, "", "trade already accepted", nil
+	}
+
+	return false, "", "create trade success", nil
+}
+
 func PrintStack() string {
 	var buf [4096]byte
 	n := runtime.Stack(buf[:], false)
 	return string(buf[:n])
 }
 
-func CreateTradde(c *gin.Context) {
-	r := &Response{
-		Code:    http.StatusOK,
-		Message: "success",
+// submitTrade check and record one trade, it returns the error code and the message
+func submitTrade(in *InCreateTrade, limiter *tradeRateLimiter) (*OutCreateTrade, string, string, error) {
+	minerID, err := canonicalAddress(in.MinerID)
+	if err != nil {
+		return nil, ErrInvalidParams, "invalid miner address", err
 	}
-	defer func(r *Response) {
-		err := recover()
-		if err != nil {
-			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Stack": PrintStack()}).Fatalf("TradeStatusTask panic")
-			c.JSON(http.StatusInternalServerError, r)
-		} else {
-			c.JSON(http.StatusOK, r)
-		}
-	}(r)
 
-	var in = InCreateTrade{}
-	err := c.ShouldBind(&in)
+	//a retry of an accepted trade returns the stored one
+	accepted, err := getAcceptedTrade(context.Background(), db.GetDB(), minerID, in.Nonce, in.Signature)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTradde parse parmeter failed")
-		r.Code = http.StatusBadRequest
-		r.Message = "invalid input parameters"
-		return
+		return nil, ErrInternal, "get accepted trade failed", err
+	}
+
+	if accepted != nil {
+		return newOutCreateTrade(tradeAlreadyAccepted, accepted), "", "trade already accepted", nil
 	}
 
 	//after check , insert db
 	tradePrice, err := getTokenPrice(in.Token, in.Timestamp)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTradde getTokenPrice failed")
-		r.Code = http.StatusBadRequest
-		r.Message = "get token price failed"
-		return
+		return nil, ErrPriceUnavailable, "get token price failed", err
 	}
 
 	newTrade := &model.AdsTokenTrade{
-		MinerID:         in.MinerID,
+		MinerID:         minerID,
 		PubKey:          in.PubKey,
 		Nonce:           in.Nonce,
 		TokenAddress:    in.Token,
@@ -336,42 +567,67 @@ func CreateTradde(c *gin.Context) {
 		Signature:       in.Signature,
 		Status:          1,
 		Leverage:        in.Leverage,
+		KeyType:         in.KeyType,
+		SignedMinerID:   in.MinerID,
 		CreatedAt:       time.Now().UTC(),
 		UpdatedAt:       time.Now().UTC(),
 	}
 
-	logger.Logrus.WithFields(logrus.Fields{"Trade": newTrade}).Info("CreateTradde info")
+	logger.Logrus.WithFields(logrus.Fields{"Trade": newTrade}).Info("submitTrade info")
 
 	//check trade rules
-	oldTrade, err := getLatestTrade(newTrade.MinerID, newTrade.TokenAddress)
+	errCode, errmsg, err := checknewtrade(newTrade, limiter)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTrade getLatestTrade failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "get latest trade failed"
-		return
+		return nil, errCode, errmsg, err
 	}
 
-	errmsg, err := checkTrade(oldTrade, newTrade)
+	// the rate limits charged by checknewtrade only count the recorded trades
+	duplicate, errCode, errmsg, err := createTrade(newTrade)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTrade checkTrade failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = errmsg
-		return
+		limiter.refund()
+		return nil, errCode, errmsg, err
+	}
+
+	if duplicate {
+		limiter.refund()
+		return newOutCreateTrade(tradeAlreadyAccepted, newTrade), "", errmsg, nil
 	}
 
-	err = insertTrade(newTrade)
+	return newOutCreateTrade(tradeAccepted, newTrade), "", "create trade success", nil
+}
+
+func CreateTradde(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		err := recover()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Stack": PrintStack()}).Fatalf("TradeStatusTask panic")
+			c.JSON(http.StatusInternalServerError, r)
+		} else {
+			writeResponse(c, r)
+		}
+	}(r)
+
+	var in = InCreateTrade{}
+	err := c.ShouldBind(&in)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTrade InsertTrade failed")
-		r.Code = http.StatusInternalServerError
-		r.Message = "record trade failed"
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTradde parse parmeter failed")
+		r.Fail(ErrInvalidParams, "invalid input parameters")
 		return
 	}
 
-	err = updatePrice4H(oldTrade, newTrade)
+	limiter := newTradeRateLimiter(false, c.FullPath())
+	out, errCode, errmsg, err := submitTrade(&in, limiter)
+	setRateLimitHeaders(c, limiter.result)
 	if err != nil {
-		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Warn("CreateTrade updatePrice4H failed")
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTradde submitTrade failed")
+		r.Fail(errCode, errmsg)
+		return
 	}
 
-	r.Message = "create trade success"
-	r.Data = ""
+	r.Message = errmsg
+	r.Data = out
 }
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxBatchTrades = 20
+
+	tradeRejected = "rejected"
+)
+
+type OutBatchTrade struct {
+	Index   int                  `json:"index"`
+	Status  string               `json:"status"`
+	ErrCode string               `json:"err_code,omitempty"`
+	Message string               `json:"msg"`
+	Trade   *model.ResTokenTrade `json:"trade,omitempty"`
+}
+
+func CreateTrades(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	var in = make([]InCreateTrade, 0)
+	err := c.ShouldBindJSON(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("CreateTrades parse parmeter failed")
+		r.Fail(ErrInvalidParams, "invalid input parameters")
+		return
+	}
+
+	if len(in) == 0 || len(in) > maxBatchTrades {
+		logger.Logrus.WithFields(logrus.Fields{"Size": len(in)}).Error("CreateTrades batch size invalid")
+		r.Fail(ErrInvalidParams, "batch size must be between 1 and 20")
+		return
+	}
+
+	limiter := newTradeRateLimiter(true, c.FullPath())
+	result := make([]OutBatchTrade, 0, len(in))
+	accepted := 0
+
+	for i := range in {
+		item := OutBatchTrade{Index: i}
+
+		out, errCode, errmsg, err := submitTrade(&in[i], limiter)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Index": i}).Error("CreateTrades submitTrade failed")
+			item.Status = tradeRejected
+			item.ErrCode = errCode
+			item.Message = errmsg
+		} else {
+			accepted++
+			item.Status = out.Status
+			item.Message = errmsg
+			item.Trade = &out.Trade
+		}
+
+		result = append(result, item)
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Size": len(in), "Accepted": accepted}).Info("CreateTrades info")
+
+	setRateLimitHeaders(c, limiter.result)
+
+	r.Message = "create trades finished"
+	r.Data = result
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/core/redis"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type TradeRuleFailure struct {
+	Rule    string `json:"rule"`
+	ErrCode string `json:"err_code"`
+	Message string `json:"msg"`
+}
+
+type OutValidateTrade struct {
+	Valid     bool               `json:"valid"`
+	Price     float64            `json:"price"`
+	PriceTime string             `json:"price_time"`
+	Leverage  float64            `json:"leverage"`
+	Failures  []TradeRuleFailure `json:"failures"`
+}
+
+// validateTrade run every trade rule without writing the database or charging the rate limits
+func validateTrade(in *InCreateTrade) (*OutValidateTrade, error) {
+	res := &OutValidateTrade{
+		Failures: make([]TradeRuleFailure, 0),
+	}
+	fail := func(rule, errCode, msg string) {
+		res.Failures = append(res.Failures, TradeRuleFailure{Rule: rule, ErrCode: errCode, Message: msg})
+	}
+
+	minerID, err := canonicalAddress(in.MinerID)
+	if err != nil {
+		fail("address", ErrInvalidParams, "invalid miner address")
+		return res, nil
+	}
+
+	newTrade := &model.AdsTokenTrade{
+		MinerID:         minerID,
+		PubKey:          in.PubKey,
+		Nonce:           in.Nonce,
+		TokenAddress:    in.Token,
+		PositionManager: in.PositionManager,
+		Direction:       in.Direction,
+		Timestamp:       in.Timestamp,
+		Signature:       in.Signature,
+		Status:          1,
+		Leverage:        in.Leverage,
+		KeyType:         in.KeyType,
+		SignedMinerID:   in.MinerID,
+	}
+
+	// the state of the miner is only shown to its signer
+	for _, rule := range tradeSignRules {
+		if errCode, msg, err := rule.check(newTrade); err != nil {
+			fail(rule.name, errCode, msg)
+		}
+	}
+	if len(res.Failures) != 0 {
+		return res, nil
+	}
+
+	accepted, err := getAcceptedTrade(context.Background(), db.GetDB(), minerID, in.Nonce, in.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if accepted != nil {
+		fail("nonce", ErrNonceInvalid, "trade already accepted")
+	}
+
+	tradePrice, err := getTokenPrice(in.Token, in.Timestamp)
+	if err != nil {
+		fail("price", ErrPriceUnavailable, "get token price failed")
+	} else {
+		res.Price = tradePrice.Price
+		res.PriceTime = tradePrice.Pt
+		newTrade.TradePrice = tradePrice.Price
+	}
+
+	for _, msg := range PeekTradeRateLimits(newTrade.PubKey, newTrade.MinerID, newTrade.TokenAddress, "/createtrade") {
+		fail("rate_limit", ErrRateLimited, msg)
+	}
+
+	for _, rule := range tradeParamRules {
+		if errCode, msg, err := rule.check(newTrade); err != nil {
+			fail(rule.name, errCode, msg)
+		}
+	}
+	res.Leverage = newTrade.Leverage
+
+	oldTrade, err := latestTrade(context.Background(), db.GetDB(), newTrade.MinerID, newTrade.TokenAddress, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if newTrade.PositionManager == "open" && oldTrade != nil && oldTrade.PositionManager == "open" {
+		cv, err := redis.GetCounterValue(leverageCounterKey(newTrade))
+		if err != nil {
+			return nil, err
+		}
+		if limit, _ := getRateLimitPolicy().leverageIncrease(); cv >= limit {
+			fail("leverage_limit", ErrLeverageIncreaseLocked, "open trade limit exceeded")
+		}
+	} else if errCode, msg, err := checktwotrade(oldTrade, newTrade); err != nil {
+		fail("latest_trade", errCode, msg)
+	}
+
+	res.Valid = len(res.Failures) == 0
+
+	return res, nil
+}
+
+func ValidateTrade(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+	defer func(r *Response) {
+		writeResponse(c, r)
+	}(r)
+
+	var in = InCreateTrade{}
+	err := c.ShouldBind(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("ValidateTrade parse parmeter failed")
+		r.Fail(ErrInvalidParams, "invalid input parameters")
+		return
+	}
+
+	result, err := validateTrade(&in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("ValidateTrade validateTrade failed")
+		r.Fail(ErrInternal, "validate trade failed")
+		return
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Trade": in, "Result": result}).Info("ValidateTrade info")
+
+	r.Message = "validate trade success"
+	r.Data = result
+}
@@ -0,0 +1,128 @@
+package whitelist
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"sync"
+
+	"github.com/Open0xScope/CommuneXService/core/db"
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/Open0xScope/CommuneXService/utils/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// the most addresses known not to be whitelisted kept between two refreshes
+const maxAbsent = 100000
+
+var (
+	mutex   sync.RWMutex
+	entries = make(map[string]model.AdsMinerWhitelist)
+	// addresses read from the database and not whitelisted, until the next refresh
+	absent = make(map[string]struct{})
+
+	hits      = expvar.NewInt("whitelist_cache_hits")
+	misses    = expvar.NewInt("whitelist_cache_misses")
+	refreshes = expvar.NewInt("whitelist_cache_refreshes")
+	size      = expvar.NewInt("whitelist_cache_size")
+)
+
+func init() {
+	expvar.Publish("whitelist_cache_hit_rate", expvar.Func(func() interface{} {
+		h, m := hits.Value(), misses.Value()
+		if h+m == 0 {
+			return 0.0
+		}
+		return float64(h) / float64(h+m)
+	}))
+}
+
+// Refresh reload the whole whitelist
+func Refresh() error {
+	res := make([]model.AdsMinerWhitelist, 0)
+	err := db.GetDB().NewSelect().Model(&res).Column("address", "uid", "stake", "status").Scan(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fresh := make(map[string]model.AdsMinerWhitelist, len(res))
+	for _, v := range res {
+		fresh[v.Address] = v
+	}
+
+	mutex.Lock()
+	entries = fresh
+	absent = make(map[string]struct{})
+	mutex.Unlock()
+
+	refreshes.Add(1)
+	size.Set(int64(len(fresh)))
+
+	return nil
+}
+
+// Get return the whitelist entry of the address, sql.ErrNoRows when it is not whitelisted.
+// An address missing from the cache is read once from the database since it may be registered after
+// the last refresh, the result is kept until the next refresh or invalidation
+func Get(address string) (*model.AdsMinerWhitelist, error) {
+	mutex.RLock()
+	entry, ok := entries[address]
+	_, isAbsent := absent[address]
+	mutex.RUnlock()
+
+	if ok {
+		hits.Add(1)
+		return &entry, nil
+	}
+	if isAbsent {
+		hits.Add(1)
+		return nil, sql.ErrNoRows
+	}
+	misses.Add(1)
+
+	var res model.AdsMinerWhitelist
+	err := db.GetDB().NewSelect().Model(&res).Where("address = ?", address).Scan(context.Background())
+	if err == sql.ErrNoRows {
+		mutex.Lock()
+		if len(absent) < maxAbsent {
+			absent[address] = struct{}{}
+		}
+		mutex.Unlock()
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mutex.Lock()
+	entries[address] = res
+	size.Set(int64(len(entries)))
+	mutex.Unlock()
+
+	return &res, nil
+}
+
+// Invalidate drop the address from the cache, it is read again from the database on the next lookup
+func Invalidate(address string) {
+	mutex.Lock()
+	delete(entries, address)
+	delete(absent, address)
+	size.Set(int64(len(entries)))
+	mutex.Unlock()
+}
+
+// InitCache load the whitelist before the service accepts requests
+func InitCache() error {
+	err := Refresh()
+	if err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Size": size.Value()}).Info("InitCache whitelist loaded")
+	return nil
+}
+
+// IsNotFound check the error of Get means the address is not whitelisted
+func IsNotFound(err error) bool {
+	return err == sql.ErrNoRows
+}
@@ -0,0 +1,35 @@
+package whitelist
+
+import (
+	"testing"
+
+	"github.com/Open0xScope/CommuneXService/core/model"
+	"github.com/stretchr/testify/require"
+)
+
+// the lookups served by the cache never reach the database, which is not set up here
+func TestGetFromCache(t *testing.T) {
+	mutex.Lock()
+	entries = map[string]model.AdsMinerWhitelist{"miner": {Address: "miner", Status: 1}}
+	absent = map[string]struct{}{"unknown": {}}
+	mutex.Unlock()
+
+	before := misses.Value()
+
+	entry, err := Get("miner")
+	require.NoError(t, err)
+	require.Equal(t, 1, entry.Status)
+
+	for i := 0; i < 3; i++ {
+		_, err = Get("unknown")
+		require.True(t, IsNotFound(err))
+	}
+
+	require.Equal(t, before, misses.Value())
+
+	Invalidate("unknown")
+	mutex.RLock()
+	_, isAbsent := absent["unknown"]
+	mutex.RUnlock()
+	require.False(t, isAbsent)
+}